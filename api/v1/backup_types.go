@@ -0,0 +1,158 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// BackupMethod is the method used to take a physical backup of the cluster
+type BackupMethod string
+
+const (
+	// BackupMethodBarmanObjectStore means the backup is taken using
+	// Barman and stored in an object store, the method used by every
+	// Backup before BackupMethodVolumeSnapshot was introduced
+	BackupMethodBarmanObjectStore = BackupMethod("barmanObjectStore")
+
+	// BackupMethodVolumeSnapshot means the backup is taken by asking the
+	// CSI driver of the PGDATA PVC to create a VolumeSnapshot
+	BackupMethodVolumeSnapshot = BackupMethod("volumeSnapshot")
+)
+
+// BackupPhase is the phase of the backup
+type BackupPhase string
+
+const (
+	// BackupPhasePending means that the backup is still waiting to be started
+	BackupPhasePending = BackupPhase("pending")
+
+	// BackupPhaseRunning means that the backup is running
+	BackupPhaseRunning = BackupPhase("running")
+
+	// BackupPhaseCompleted means that the backup is now completed
+	BackupPhaseCompleted = BackupPhase("completed")
+
+	// BackupPhaseFailed means that the backup is failed
+	BackupPhaseFailed = BackupPhase("failed")
+)
+
+// BackupSpec defines the desired state of Backup
+type BackupSpec struct {
+	// The cluster to backup
+	Cluster LocalObjectReference `json:"cluster"`
+
+	// The method used to take the backup. Defaults to
+	// BackupMethodBarmanObjectStore for backward compatibility with
+	// clusters that only configure a Barman object store.
+	// +kubebuilder:validation:Enum=barmanObjectStore;volumeSnapshot
+	// +kubebuilder:default:=barmanObjectStore
+	// +optional
+	Method BackupMethod `json:"method,omitempty"`
+}
+
+// VolumeSnapshotBackupStatus records where the CSI VolumeSnapshot taken for
+// a Method: volumeSnapshot Backup can be found
+type VolumeSnapshotBackupStatus struct {
+	// The name of the VolumeSnapshot object created for the PGDATA PVC
+	// +optional
+	VolumeSnapshotName string `json:"volumeSnapshotName,omitempty"`
+
+	// The content handle reported by the CSI driver once the snapshot has
+	// been cut. Its presence marks the Backup as usable to bootstrap a new
+	// Cluster, whose PVC dataSource references the VolumeSnapshot by
+	// VolumeSnapshotName
+	// +optional
+	VolumeSnapshotContentName string `json:"volumeSnapshotContentName,omitempty"`
+}
+
+// BackupStatus defines the observed state of Backup
+type BackupStatus struct {
+	// The last backup status
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// The moment where the backup was started
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// The moment where the backup was terminated
+	// +optional
+	StoppedAt *metav1.Time `json:"stoppedAt,omitempty"`
+
+	// The detected error, if any
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// The snapshot coordinates, populated only when Spec.Method is
+	// BackupMethodVolumeSnapshot
+	// +optional
+	VolumeSnapshot *VolumeSnapshotBackupStatus `json:"volumeSnapshot,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=backup,categories=postgresql
+
+// Backup is the Schema for the backups API
+type Backup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BackupSpec   `json:"spec,omitempty"`
+	Status BackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// BackupList contains a list of Backup
+type BackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Backup `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *Backup) DeepCopyObject() runtime.Object {
+	out := *in
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	if in.Status.StartedAt != nil {
+		out.Status.StartedAt = in.Status.StartedAt.DeepCopy()
+	}
+	if in.Status.StoppedAt != nil {
+		out.Status.StoppedAt = in.Status.StoppedAt.DeepCopy()
+	}
+	if in.Status.VolumeSnapshot != nil {
+		snapshot := *in.Status.VolumeSnapshot
+		out.Status.VolumeSnapshot = &snapshot
+	}
+	return &out
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *BackupList) DeepCopyObject() runtime.Object {
+	out := *in
+	out.Items = make([]Backup, len(in.Items))
+	for i := range in.Items {
+		out.Items[i] = *in.Items[i].DeepCopyObject().(*Backup)
+	}
+	return &out
+}
+
+// IsCompletedVolumeSnapshot returns true when this Backup was taken with the
+// CSI VolumeSnapshot method and reports a usable content handle
+func (in *Backup) IsCompletedVolumeSnapshot() bool {
+	return in.Spec.Method == BackupMethodVolumeSnapshot &&
+		in.Status.Phase == BackupPhaseCompleted &&
+		in.Status.VolumeSnapshot != nil &&
+		in.Status.VolumeSnapshot.VolumeSnapshotContentName != ""
+}
+
+func init() {
+	SchemeBuilder.Register(&Backup{}, &BackupList{})
+}
@@ -0,0 +1,145 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ClusterSpec defines the desired state of Cluster
+type ClusterSpec struct {
+	// Number of instances required in the cluster
+	// +kubebuilder:validation:Min=1
+	Instances int `json:"instances"`
+
+	// Instructions to bootstrap this cluster
+	// +optional
+	Bootstrap *BootstrapConfiguration `json:"bootstrap,omitempty"`
+}
+
+// BootstrapConfiguration describes how to bootstrap this Cluster
+type BootstrapConfiguration struct {
+	// Bootstrap the cluster restoring a backup
+	// +optional
+	Recovery *BootstrapRecovery `json:"recovery,omitempty"`
+}
+
+// BootstrapRecovery describes a recovery bootstrap, that is, creating a new
+// cluster by restoring it from an existing Backup
+type BootstrapRecovery struct {
+	// The name of the backup from which to restore
+	// +optional
+	Backup *LocalObjectReference `json:"backup,omitempty"`
+}
+
+// LocalObjectReference contains enough information to let you locate a local
+// object with a known type inside the same namespace
+type LocalObjectReference struct {
+	// Name of the referent
+	Name string `json:"name"`
+}
+
+// ClusterStatus defines the observed state of Cluster
+type ClusterStatus struct {
+	// The total number of ready instances in the cluster
+	// +optional
+	ReadyInstances int `json:"readyInstances,omitempty"`
+
+	// Current primary instance
+	// +optional
+	CurrentPrimary string `json:"currentPrimary,omitempty"`
+
+	// Target primary instance, i.e. the instance that should be the primary
+	// after the reconciliation loop converges
+	// +optional
+	TargetPrimary string `json:"targetPrimary,omitempty"`
+
+	// The timestamp when the last actual promotion to primary has occurred
+	// +optional
+	CurrentPrimaryTimestamp string `json:"currentPrimaryTimestamp,omitempty"`
+
+	// The list of CRD storage versions under which at least one stored
+	// object still exists, used to decide when it's safe to drop conversion
+	// support for an old version
+	// +optional
+	StoredVersions []string `json:"storedVersions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=cluster,categories=postgresql
+
+// Cluster is the Schema for the PostgreSQL API
+type Cluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterSpec   `json:"spec,omitempty"`
+	Status ClusterStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterList contains a list of Cluster
+type ClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Cluster `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *Cluster) DeepCopyObject() runtime.Object {
+	out := &Cluster{}
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *ClusterList) DeepCopyObject() runtime.Object {
+	out := *in
+	out.Items = make([]Cluster, len(in.Items))
+	for i := range in.Items {
+		in.Items[i].DeepCopyInto(&out.Items[i])
+	}
+	return &out
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *Cluster) DeepCopyInto(out *Cluster) {
+	*out = *in
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	if in.Spec.Bootstrap != nil {
+		out.Spec.Bootstrap = in.Spec.Bootstrap.DeepCopy()
+	}
+	if in.Status.StoredVersions != nil {
+		out.Status.StoredVersions = append([]string(nil), in.Status.StoredVersions...)
+	}
+}
+
+// DeepCopy returns a deep copy of in
+func (in *BootstrapConfiguration) DeepCopy() *BootstrapConfiguration {
+	out := *in
+	if in.Recovery != nil {
+		out.Recovery = in.Recovery.DeepCopy()
+	}
+	return &out
+}
+
+// DeepCopy returns a deep copy of in
+func (in *BootstrapRecovery) DeepCopy() *BootstrapRecovery {
+	out := *in
+	if in.Backup != nil {
+		backup := *in.Backup
+		out.Backup = &backup
+	}
+	return &out
+}
+
+func init() {
+	SchemeBuilder.Register(&Cluster{}, &ClusterList{})
+}
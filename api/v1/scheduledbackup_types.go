@@ -0,0 +1,81 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ScheduledBackupSpec defines the desired state of ScheduledBackup
+type ScheduledBackupSpec struct {
+	// The schedule, expressed as a cron-style string, on which new Backups
+	// should be created
+	Schedule string `json:"schedule"`
+
+	// The cluster to backup
+	Cluster LocalObjectReference `json:"cluster"`
+
+	// The method used for the Backups created by this ScheduledBackup
+	// +kubebuilder:validation:Enum=barmanObjectStore;volumeSnapshot
+	// +kubebuilder:default:=barmanObjectStore
+	// +optional
+	Method BackupMethod `json:"method,omitempty"`
+}
+
+// ScheduledBackupStatus defines the observed state of ScheduledBackup
+type ScheduledBackupStatus struct {
+	// The last time this ScheduledBackup was scheduled
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=scheduledbackup,categories=postgresql
+
+// ScheduledBackup is the Schema for the scheduledbackups API
+type ScheduledBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ScheduledBackupSpec   `json:"spec,omitempty"`
+	Status ScheduledBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ScheduledBackupList contains a list of ScheduledBackup
+type ScheduledBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ScheduledBackup `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *ScheduledBackup) DeepCopyObject() runtime.Object {
+	out := *in
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	if in.Status.LastScheduleTime != nil {
+		out.Status.LastScheduleTime = in.Status.LastScheduleTime.DeepCopy()
+	}
+	return &out
+}
+
+// DeepCopyObject implements runtime.Object
+func (in *ScheduledBackupList) DeepCopyObject() runtime.Object {
+	out := *in
+	out.Items = make([]ScheduledBackup, len(in.Items))
+	for i := range in.Items {
+		out.Items[i] = *in.Items[i].DeepCopyObject().(*ScheduledBackup)
+	}
+	return &out
+}
+
+func init() {
+	SchemeBuilder.Register(&ScheduledBackup{}, &ScheduledBackupList{})
+}
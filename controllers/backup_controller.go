@@ -0,0 +1,122 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+// Package controllers contains the controller reconciliation loops
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/EnterpriseDB/cloud-native-postgresql/api/v1"
+)
+
+// BackupReconciler reconciles a Backup object
+type BackupReconciler struct {
+	client.Client
+}
+
+// Reconcile is the main reconciliation loop for a Backup
+func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var backup apiv1.Backup
+	if err := r.Get(ctx, req.NamespacedName, &backup); err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if backup.Status.Phase == apiv1.BackupPhaseCompleted || backup.Status.Phase == apiv1.BackupPhaseFailed {
+		return ctrl.Result{}, nil
+	}
+
+	switch backup.Spec.Method {
+	case apiv1.BackupMethodVolumeSnapshot:
+		return r.reconcileVolumeSnapshotBackup(ctx, &backup)
+	default:
+		return r.reconcileObjectStoreBackup(ctx, &backup)
+	}
+}
+
+// reconcileObjectStoreBackup handles a Method: barmanObjectStore Backup. It
+// is a no-op: it neither starts the backup nor updates the Backup status.
+func (r *BackupReconciler) reconcileObjectStoreBackup(ctx context.Context, backup *apiv1.Backup) (ctrl.Result, error) {
+	// The object-store backup is executed by the instance manager of the
+	// elected backup pod, not by this reconciler.
+	return ctrl.Result{}, nil
+}
+
+// reconcileVolumeSnapshotBackup drives a Method: volumeSnapshot Backup: it
+// ensures a VolumeSnapshot of the target Cluster's PGDATA PVC exists, then
+// copies the resulting VolumeSnapshotContent handle into Backup.Status once
+// the CSI driver reports the snapshot as ready to use.
+func (r *BackupReconciler) reconcileVolumeSnapshotBackup(ctx context.Context, backup *apiv1.Backup) (ctrl.Result, error) {
+	var cluster apiv1.Cluster
+	clusterKey := types.NamespacedName{Namespace: backup.Namespace, Name: backup.Spec.Cluster.Name}
+	if err := r.Get(ctx, clusterKey, &cluster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("while getting cluster %s for volume snapshot backup: %w", clusterKey, err)
+	}
+
+	if cluster.Status.CurrentPrimary == "" {
+		return ctrl.Result{}, fmt.Errorf("cluster %s has no current primary yet, cannot pick a PVC to snapshot", clusterKey)
+	}
+	pvcName := cluster.Status.CurrentPrimary
+	snapshotName := backup.Name
+
+	var snapshot snapshotv1.VolumeSnapshot
+	err := r.Get(ctx, types.NamespacedName{Namespace: backup.Namespace, Name: snapshotName}, &snapshot)
+	switch {
+	case apierrs.IsNotFound(err):
+		snapshot = snapshotv1.VolumeSnapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      snapshotName,
+				Namespace: backup.Namespace,
+			},
+			Spec: snapshotv1.VolumeSnapshotSpec{
+				Source: snapshotv1.VolumeSnapshotSource{
+					PersistentVolumeClaimName: &pvcName,
+				},
+			},
+		}
+		if err := r.Create(ctx, &snapshot); err != nil {
+			return ctrl.Result{}, fmt.Errorf("while creating volume snapshot %s: %w", snapshotName, err)
+		}
+		backup.Status.Phase = apiv1.BackupPhaseRunning
+		backup.Status.VolumeSnapshot = &apiv1.VolumeSnapshotBackupStatus{VolumeSnapshotName: snapshotName}
+		return ctrl.Result{Requeue: true}, r.Status().Update(ctx, backup)
+	case err != nil:
+		return ctrl.Result{}, fmt.Errorf("while getting volume snapshot %s: %w", snapshotName, err)
+	}
+
+	if snapshot.Status == nil || snapshot.Status.ReadyToUse == nil || !*snapshot.Status.ReadyToUse ||
+		snapshot.Status.BoundVolumeSnapshotContentName == nil {
+		// Not ready yet, come back later
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	backup.Status.Phase = apiv1.BackupPhaseCompleted
+	backup.Status.VolumeSnapshot = &apiv1.VolumeSnapshotBackupStatus{
+		VolumeSnapshotName:        snapshotName,
+		VolumeSnapshotContentName: *snapshot.Status.BoundVolumeSnapshotContentName,
+	}
+	return ctrl.Result{}, r.Status().Update(ctx, backup)
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *BackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.Backup{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Complete(r)
+}
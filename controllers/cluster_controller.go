@@ -0,0 +1,107 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	apiv1 "github.com/EnterpriseDB/cloud-native-postgresql/api/v1"
+	backupreconciler "github.com/EnterpriseDB/cloud-native-postgresql/pkg/reconciler/backup"
+)
+
+// ClusterReconciler reconciles a Cluster object
+type ClusterReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// Reconcile ensures every instance of the Cluster has a PGDATA PVC,
+// pre-populated from a recovery Backup's dataSource when the Cluster is
+// bootstrapping via BootstrapRecovery.
+func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var cluster apiv1.Cluster
+	if err := r.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		if apierrs.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	for instance := 1; instance <= cluster.Spec.Instances; instance++ {
+		if err := r.ensureInstancePVC(ctx, &cluster, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// ensureInstancePVC creates the PGDATA PVC for the given instance number if
+// it doesn't already exist. When the Cluster is bootstrapping from a
+// recovery Backup, the PVC is created with a dataSource resolved by
+// backupreconciler.GetRecoveryDataSource, so a Method: volumeSnapshot
+// Backup actually pre-populates the volume instead of starting it empty.
+func (r *ClusterReconciler) ensureInstancePVC(ctx context.Context, cluster *apiv1.Cluster, instance int) error {
+	pvcName := fmt.Sprintf("%s-%d", cluster.Name, instance)
+	pvcKey := types.NamespacedName{Namespace: cluster.Namespace, Name: pvcName}
+
+	var existing corev1.PersistentVolumeClaim
+	err := r.Get(ctx, pvcKey, &existing)
+	if err == nil {
+		return nil
+	}
+	if !apierrs.IsNotFound(err) {
+		return fmt.Errorf("while checking for PVC %s: %w", pvcKey, err)
+	}
+
+	var dataSource *corev1.TypedLocalObjectReference
+	if cluster.Spec.Bootstrap != nil && cluster.Spec.Bootstrap.Recovery != nil {
+		dataSource, err = backupreconciler.GetRecoveryDataSource(
+			ctx, r.Client, cluster.Namespace, cluster.Spec.Bootstrap.Recovery)
+		if err != nil {
+			return fmt.Errorf("while resolving recovery data source for %s: %w", pvcKey, err)
+		}
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvcName,
+			Namespace: cluster.Namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			DataSource:  dataSource,
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(cluster, pvc, r.Scheme); err != nil {
+		return fmt.Errorf("while setting the owner reference on PVC %s: %w", pvcKey, err)
+	}
+
+	if err := r.Create(ctx, pvc); err != nil {
+		return fmt.Errorf("while creating PVC %s: %w", pvcKey, err)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&apiv1.Cluster{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Complete(r)
+}
@@ -0,0 +1,49 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/EnterpriseDB/cloud-native-postgresql/api/v1"
+	"github.com/EnterpriseDB/cloud-native-postgresql/pkg/utils/gvkcheck"
+)
+
+var gvkStartupCheckLog = ctrl.Log.WithName("gvkcheck")
+
+// WatchedGVKs lists every GroupVersionKind the operator watches or
+// reconciles, at the version this build was compiled against. main() is
+// expected to call VerifyWatchedGVKs with the manager's client before
+// mgr.Start(), and to exit on a non-nil error instead of starting: a
+// reconciler that first discovers a missing kind mid-reconcile crash-loops
+// far less legibly than a manager that refuses to start.
+var WatchedGVKs = []gvkcheck.WatchedGVK{
+	{GVK: apiv1.GroupVersion.WithKind(apiv1.ClusterKind), PreferredVersion: apiv1.GroupVersion.Version},
+	{GVK: apiv1.GroupVersion.WithKind(apiv1.BackupKind), PreferredVersion: apiv1.GroupVersion.Version},
+	{GVK: apiv1.GroupVersion.WithKind(apiv1.ScheduledBackupKind), PreferredVersion: apiv1.GroupVersion.Version},
+}
+
+// VerifyWatchedGVKs checks WatchedGVKs against the CRDs installed on the
+// cluster c talks to, returning an actionable, multi-line error when any
+// of them is missing, served at the wrong version, or behind an
+// unreachable conversion webhook. A storage-version that hasn't caught up
+// with the operator's preferred version is only logged as a warning: it
+// resolves itself as objects get re-encoded and must not block startup.
+func VerifyWatchedGVKs(ctx context.Context, c client.Client) error {
+	verifier := gvkcheck.Verifier{Client: c, Watched: WatchedGVKs}
+	mismatches, err := verifier.Verify(ctx)
+	if err != nil {
+		return err
+	}
+	for _, warning := range gvkcheck.FormatWarnings(mismatches) {
+		gvkStartupCheckLog.Info(warning)
+	}
+	return gvkcheck.FormatMismatches(mismatches)
+}
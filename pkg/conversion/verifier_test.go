@@ -0,0 +1,50 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package conversion
+
+import (
+	"testing"
+)
+
+func TestDiffDetectsNoChange(t *testing.T) {
+	before := []byte(`{"spec":{"instances":3}}`)
+	after := []byte(`{"spec":{"instances":3}}`)
+
+	diff, err := Diff(before, after)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff != "" {
+		t.Fatalf("expected no diff for identical snapshots, got: %v", diff)
+	}
+}
+
+func TestDiffDetectsDroppedField(t *testing.T) {
+	before := []byte(`{"spec":{"instances":3,"bootstrap":{"recovery":{"backup":{"name":"b1"}}}}}`)
+	after := []byte(`{"spec":{"instances":3}}`)
+
+	diff, err := Diff(before, after)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff == "" {
+		t.Fatal("expected a diff to be reported when a field is silently dropped")
+	}
+}
+
+func TestDiffDetectsMutatedDefault(t *testing.T) {
+	before := []byte(`{"spec":{"method":"barmanObjectStore"}}`)
+	after := []byte(`{"spec":{"method":"volumeSnapshot"}}`)
+
+	diff, err := Diff(before, after)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff == "" {
+		t.Fatal("expected a diff to be reported when a default is mutated")
+	}
+}
@@ -0,0 +1,68 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+// Package backup contains the reconciliation logic shared by the operator
+// when creating a new Cluster instance out of an existing Backup
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "github.com/EnterpriseDB/cloud-native-postgresql/api/v1"
+)
+
+// ErrVolumeSnapshotBackupNotUsable is returned when a BootstrapRecovery
+// references a Backup that cannot be used to pre-populate a PVC via
+// dataSource, either because it isn't a completed volumeSnapshot backup or
+// because it doesn't carry a VolumeSnapshotContent handle yet
+var ErrVolumeSnapshotBackupNotUsable = fmt.Errorf("backup is not a usable volume snapshot source")
+
+// GetRecoveryDataSource returns the PVC DataSource that should be used to
+// bootstrap a new instance's PGDATA volume when the Cluster is being
+// recovered from a Method: volumeSnapshot Backup. It returns nil, nil when
+// the referenced Backup exists but was not taken with the volumeSnapshot
+// method, in which case the caller should fall back to the usual
+// Barman-based WAL restore bootstrap.
+func GetRecoveryDataSource(
+	ctx context.Context,
+	c client.Client,
+	namespace string,
+	recovery *apiv1.BootstrapRecovery,
+) (*corev1.TypedLocalObjectReference, error) {
+	if recovery == nil || recovery.Backup == nil {
+		return nil, nil
+	}
+
+	var backup apiv1.Backup
+	backupKey := types.NamespacedName{Namespace: namespace, Name: recovery.Backup.Name}
+	if err := c.Get(ctx, backupKey, &backup); err != nil {
+		if apierrs.IsNotFound(err) {
+			return nil, fmt.Errorf("recovery backup %s not found: %w", backupKey, err)
+		}
+		return nil, err
+	}
+
+	if backup.Spec.Method != apiv1.BackupMethodVolumeSnapshot {
+		return nil, nil
+	}
+
+	if !backup.IsCompletedVolumeSnapshot() {
+		return nil, ErrVolumeSnapshotBackupNotUsable
+	}
+
+	snapshotAPIGroup := "snapshot.storage.k8s.io"
+	return &corev1.TypedLocalObjectReference{
+		APIGroup: &snapshotAPIGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     backup.Status.VolumeSnapshot.VolumeSnapshotName,
+	}, nil
+}
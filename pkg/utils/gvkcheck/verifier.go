@@ -0,0 +1,225 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+// Package gvkcheck verifies that every GroupVersionKind the operator
+// watches or reconciles is actually served by the CRDs installed on the
+// cluster, at the version the operator was compiled against. It is shared
+// by the manager's startup path (so the operator refuses to start rather
+// than crash-looping later when a reconciler first hits a missing kind)
+// and by the e2e suite (so an upgrade that silently drops a version is
+// caught before any test that depends on it runs).
+package gvkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WatchedGVK is one of the GroupVersionKinds the operator watches or
+// reconciles, together with the version it was compiled against.
+type WatchedGVK struct {
+	GVK schema.GroupVersionKind
+
+	// PreferredVersion is the version the operator was compiled against
+	// and expects the API server to serve, and ideally store, objects as.
+	PreferredVersion string
+}
+
+// Severity classifies how serious a Mismatch is.
+type Severity int
+
+const (
+	// SeverityFatal means the GVK is not usable at all: the CRD is
+	// missing, doesn't serve the operator's preferred version, or its
+	// conversion webhook could not be reached.
+	SeverityFatal Severity = iota
+
+	// SeverityWarning means the GVK is fully usable, but something about
+	// it hasn't settled yet - currently only a storage-version that
+	// hasn't caught up with the operator's preferred version, which
+	// resolves itself as objects get re-encoded and doesn't block the
+	// operator from starting.
+	SeverityWarning
+)
+
+// Mismatch describes a single GVK that failed verification.
+type Mismatch struct {
+	GVK      schema.GroupVersionKind
+	Reason   string
+	Severity Severity
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s: %s", m.GVK, m.Reason)
+}
+
+// Verifier checks that every WatchedGVK is actually served by the
+// installed CRDs at the version the operator was compiled against.
+type Verifier struct {
+	Client  client.Client
+	Watched []WatchedGVK
+}
+
+// Verify returns one Mismatch for each check a WatchedGVK entry fails: the
+// CRD is missing, the served versions don't include PreferredVersion, the
+// CRD's storage version differs from PreferredVersion (a SeverityWarning),
+// or the CRD declares a webhook conversion strategy that could not be
+// reached. A single GVK can yield both a storage-version warning and a
+// webhook failure. The returned error is always nil: a failure to read a
+// CRD, whatever its cause (including an unreachable API server), is
+// reported as a SeverityFatal Mismatch for that GVK.
+func (v *Verifier) Verify(ctx context.Context) ([]Mismatch, error) {
+	var mismatches []Mismatch
+
+	for _, watched := range v.Watched {
+		crdName, err := CRDNameFor(v.Client, watched.GVK)
+		if err != nil {
+			mismatches = append(mismatches, Mismatch{
+				GVK: watched.GVK, Severity: SeverityFatal,
+				Reason: fmt.Sprintf("could not resolve a CRD name: %v", err),
+			})
+			continue
+		}
+
+		var crd apiextensionsv1.CustomResourceDefinition
+		if err := v.Client.Get(ctx, types.NamespacedName{Name: crdName}, &crd); err != nil {
+			mismatches = append(mismatches, Mismatch{
+				GVK: watched.GVK, Severity: SeverityFatal,
+				Reason: fmt.Sprintf("CRD %s not found: %v", crdName, err),
+			})
+			continue
+		}
+
+		if !servesVersion(crd, watched.PreferredVersion) {
+			mismatches = append(mismatches, Mismatch{
+				GVK: watched.GVK, Severity: SeverityFatal,
+				Reason: fmt.Sprintf("CRD %s does not serve version %s (served: %s)",
+					crdName, watched.PreferredVersion, strings.Join(servedVersions(crd), ", ")),
+			})
+			continue
+		}
+
+		if stored := storageVersion(crd); stored != "" && stored != watched.PreferredVersion {
+			mismatches = append(mismatches, Mismatch{
+				GVK: watched.GVK, Severity: SeverityWarning,
+				Reason: fmt.Sprintf("CRD %s stores objects at %s but the operator prefers %s",
+					crdName, stored, watched.PreferredVersion),
+			})
+		}
+
+		if crd.Spec.Conversion != nil && crd.Spec.Conversion.Strategy == apiextensionsv1.WebhookConverter {
+			if err := v.probeConversionWebhook(ctx, watched.GVK); err != nil {
+				mismatches = append(mismatches, Mismatch{
+					GVK: watched.GVK, Severity: SeverityFatal,
+					Reason: fmt.Sprintf(
+						"CRD %s declares a webhook conversion strategy that could not be reached: %v", crdName, err),
+				})
+			}
+		}
+	}
+
+	return mismatches, nil
+}
+
+// CRDNameFor resolves the <plural>.<group> CRD name backing gvk via c's
+// RESTMapper. It is exported so both the operator startup path (through
+// Verifier) and the e2e InstallationReadiness check can resolve a CRD name
+// the same way instead of keeping their own copies in sync by hand.
+func CRDNameFor(c client.Client, gvk schema.GroupVersionKind) (string, error) {
+	mapping, err := c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return "", err
+	}
+	return mapping.Resource.Resource + "." + mapping.Resource.Group, nil
+}
+
+// probeConversionWebhook issues a List at gvk's version, forcing the API
+// server to invoke the conversion webhook if the requested version isn't
+// also the storage version.
+func (v *Verifier) probeConversionWebhook(ctx context.Context, gvk schema.GroupVersionKind) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind + "List"})
+	return v.Client.List(ctx, list, client.Limit(1))
+}
+
+func servesVersion(crd apiextensionsv1.CustomResourceDefinition, version string) bool {
+	for _, v := range crd.Spec.Versions {
+		if v.Name == version && v.Served {
+			return true
+		}
+	}
+	return false
+}
+
+func servedVersions(crd apiextensionsv1.CustomResourceDefinition) []string {
+	var out []string
+	for _, v := range crd.Spec.Versions {
+		if v.Served {
+			out = append(out, v.Name)
+		}
+	}
+	return out
+}
+
+// storageVersion returns the version flagged Storage: true in the CRD
+// spec, i.e. the version new and re-encoded objects are actually
+// persisted as.
+func storageVersion(crd apiextensionsv1.CustomResourceDefinition) string {
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	return ""
+}
+
+// FormatMismatches renders the SeverityFatal entries of mismatches into a
+// single actionable error listing each failing GVK, suitable for a
+// manager's startup path to return so it refuses to start rather than
+// crash-looping later when a reconciler first hits a missing kind. A
+// storage-version drift alone (SeverityWarning) resolves itself as objects
+// get re-encoded and must not block startup, so it is not included here;
+// use FormatWarnings to log it instead. It returns nil when there are no
+// fatal mismatches.
+func FormatMismatches(mismatches []Mismatch) error {
+	var fatal []Mismatch
+	for _, m := range mismatches {
+		if m.Severity == SeverityFatal {
+			fatal = append(fatal, m)
+		}
+	}
+	if len(fatal) == 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(fatal)+1)
+	lines = append(lines, "the operator cannot start: the installed CRDs do not match what it was compiled against:")
+	for _, m := range fatal {
+		lines = append(lines, "  - "+m.String())
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// FormatWarnings renders the SeverityWarning entries of mismatches into
+// human-readable lines, one per warning, for a caller to log without
+// blocking startup. It returns nil when there are no warnings.
+func FormatWarnings(mismatches []Mismatch) []string {
+	var lines []string
+	for _, m := range mismatches {
+		if m.Severity == SeverityWarning {
+			lines = append(lines, m.String())
+		}
+	}
+	return lines
+}
@@ -0,0 +1,76 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package gvkcheck
+
+import (
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func testCRD() apiextensionsv1.CustomResourceDefinition {
+	return apiextensionsv1.CustomResourceDefinition{
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true, Storage: false},
+				{Name: "v1", Served: true, Storage: true},
+			},
+		},
+	}
+}
+
+func TestServesVersion(t *testing.T) {
+	crd := testCRD()
+
+	if !servesVersion(crd, "v1") {
+		t.Fatal("expected v1 to be served")
+	}
+	if servesVersion(crd, "v2") {
+		t.Fatal("did not expect v2 to be served")
+	}
+}
+
+func TestStorageVersion(t *testing.T) {
+	if got := storageVersion(testCRD()); got != "v1" {
+		t.Fatalf("expected storage version v1, got %v", got)
+	}
+}
+
+func TestFormatMismatchesEmpty(t *testing.T) {
+	if err := FormatMismatches(nil); err != nil {
+		t.Fatalf("expected no error for an empty mismatch list, got: %v", err)
+	}
+}
+
+func TestFormatMismatchesListsEachGVK(t *testing.T) {
+	err := FormatMismatches([]Mismatch{{Reason: "CRD not found"}})
+	if err == nil {
+		t.Fatal("expected an error when mismatches is non-empty")
+	}
+	if !strings.Contains(err.Error(), "CRD not found") {
+		t.Fatalf("expected the error to list the failing GVK's reason, got: %v", err)
+	}
+}
+
+func TestFormatMismatchesIgnoresWarnings(t *testing.T) {
+	err := FormatMismatches([]Mismatch{{Reason: "storage version drift", Severity: SeverityWarning}})
+	if err != nil {
+		t.Fatalf("expected a storage-version-only mismatch not to block startup, got: %v", err)
+	}
+}
+
+func TestFormatWarningsIgnoresFatal(t *testing.T) {
+	mismatches := []Mismatch{
+		{Reason: "CRD not found", Severity: SeverityFatal},
+		{Reason: "storage version drift", Severity: SeverityWarning},
+	}
+	warnings := FormatWarnings(mismatches)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "storage version drift") {
+		t.Fatalf("expected exactly one warning for the SeverityWarning entry, got: %v", warnings)
+	}
+}
@@ -0,0 +1,421 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	. "github.com/onsi/ginkgo"
+)
+
+const (
+	// clusterProviderEnv selects which ClusterProvider the suite acquires
+	// its cluster from. Unset (or any unrecognised value) keeps the
+	// existing behaviour of assuming an out-of-band provisioned cluster.
+	clusterProviderEnv = "E2E_CLUSTER_PROVIDER"
+
+	// clusterProviderKind and clusterProviderBoskos are the recognised
+	// values of clusterProviderEnv.
+	clusterProviderKind   = "kind"
+	clusterProviderBoskos = "boskos"
+
+	boskosURLEnv          = "BOSKOS_HOST"
+	boskosOwnerEnv        = "BOSKOS_OWNER"
+	boskosResourceTypeEnv = "BOSKOS_RESOURCE_TYPE"
+	kindNamePrefixEnv     = "KIND_CLUSTER_PREFIX"
+
+	defaultBoskosResourceType = "cnpg-e2e-cluster"
+	defaultKindNamePrefix     = "cnpg-e2e"
+)
+
+// NewClusterProviderFromEnv selects the ClusterProvider the e2e suite
+// should acquire its cluster from, based on clusterProviderEnv, so CI can
+// opt a given run into a kind-per-worker or Boskos-leased cluster without
+// recompiling the suite. It defaults to CurrentKubeconfigProvider, which
+// is the suite's original behaviour.
+func NewClusterProviderFromEnv() ClusterProvider {
+	switch os.Getenv(clusterProviderEnv) {
+	case clusterProviderKind:
+		prefix := os.Getenv(kindNamePrefixEnv)
+		if prefix == "" {
+			prefix = defaultKindNamePrefix
+		}
+		return &KindPerWorkerProvider{NamePrefix: prefix}
+	case clusterProviderBoskos:
+		resourceType := os.Getenv(boskosResourceTypeEnv)
+		if resourceType == "" {
+			resourceType = defaultBoskosResourceType
+		}
+		return &BoskosLeaseClient{
+			URL:   os.Getenv(boskosURLEnv),
+			Owner: os.Getenv(boskosOwnerEnv),
+		}
+	default:
+		return &CurrentKubeconfigProvider{}
+	}
+}
+
+// ClusterSpecFromEnv builds the ClusterSpec passed to the selected
+// provider's Acquire, reading BOSKOS_RESOURCE_TYPE so a Boskos-backed run
+// can request a pool other than defaultBoskosResourceType.
+func ClusterSpecFromEnv(name string) ClusterSpec {
+	resourceType := os.Getenv(boskosResourceTypeEnv)
+	if resourceType == "" {
+		resourceType = defaultBoskosResourceType
+	}
+	return ClusterSpec{Name: name, ResourceType: resourceType}
+}
+
+// ClusterSpec describes the cluster a ClusterProvider is asked to Acquire.
+// ResourceType is only meaningful to providers backed by a resource pool
+// (BoskosLeaseClient); the other providers ignore it.
+type ClusterSpec struct {
+	// Name is a human-readable identifier for the request; none of the
+	// providers reads it, and it is not necessarily the name of the
+	// underlying kind cluster or Boskos lease.
+	Name string
+
+	// ResourceType is the Boskos resource type to request, e.g. "cnpg-e2e-cluster".
+	ResourceType string
+}
+
+// LeasedCluster is what a ClusterProvider hands back from Acquire: enough
+// to build a controller-runtime/client-go client against the leased
+// cluster, plus whatever bookkeeping the provider needs to Release or
+// Heartbeat it later.
+type LeasedCluster struct {
+	// Config is the REST config pointing at the leased cluster.
+	Config *rest.Config
+
+	// Kubeconfig is the raw kubeconfig YAML for the leased cluster, when
+	// the provider has one to hand back (KindPerWorkerProvider,
+	// BoskosLeaseClient). CurrentKubeconfigProvider leaves it empty since
+	// the caller's environment already points at the right kubeconfig.
+	Kubeconfig []byte
+
+	// leaseName is the provider-specific identifier for this lease (a kind
+	// cluster name, a Boskos resource name, ...). Providers that don't
+	// track one (CurrentKubeconfigProvider) leave it empty. BoskosLeaseClient
+	// reads and writes it from both the caller's goroutine and the
+	// background heartbeat goroutine, so every access goes through
+	// BoskosLeaseClient.mu.
+	leaseName string
+
+	// stopHeartbeat, when non-nil, is closed by Release to stop a
+	// background heartbeat goroutine started by Acquire.
+	stopHeartbeat chan struct{}
+}
+
+// ClusterProvider acquires and releases the ephemeral clusters the e2e
+// suite runs against, mirroring how kubetest integrates with a
+// resource-leasing service rather than assuming a single already
+// provisioned cluster.
+type ClusterProvider interface {
+	// Acquire blocks until a cluster matching spec is available and
+	// returns a LeasedCluster ready to build a client from.
+	Acquire(ctx context.Context, spec ClusterSpec) (*LeasedCluster, error)
+
+	// Release gives the cluster back to the provider. It must be safe to
+	// call from a deferred, recovered panic handler, and safe to call
+	// more than once for the same LeasedCluster.
+	Release(ctx context.Context, leased *LeasedCluster) error
+
+	// Heartbeat extends the lease on leased, when the provider requires
+	// one. Providers that don't expire leases (CurrentKubeconfigProvider,
+	// KindPerWorkerProvider) treat this as a no-op.
+	Heartbeat(ctx context.Context, leased *LeasedCluster) error
+}
+
+// CurrentKubeconfigProvider is the existing behaviour of this suite: it
+// assumes a single cluster was already provisioned out-of-band and is
+// reachable through the current kubeconfig context.
+type CurrentKubeconfigProvider struct {
+	// Kubeconfig is the path to the kubeconfig file to load. An empty
+	// value uses the client-go default loading rules (KUBECONFIG env var,
+	// then ~/.kube/config).
+	Kubeconfig string
+}
+
+// Acquire loads the current kubeconfig and returns it as the leased
+// cluster. There is nothing to wait for since the cluster is assumed to
+// already exist.
+func (p *CurrentKubeconfigProvider) Acquire(_ context.Context, _ ClusterSpec) (*LeasedCluster, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if p.Kubeconfig != "" {
+		loadingRules.ExplicitPath = p.Kubeconfig
+	}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("while loading the current kubeconfig: %w", err)
+	}
+	return &LeasedCluster{Config: config}, nil
+}
+
+// Release is a no-op: the cluster wasn't provisioned by this provider, so
+// it isn't this provider's responsibility to tear it down.
+func (p *CurrentKubeconfigProvider) Release(_ context.Context, _ *LeasedCluster) error { return nil }
+
+// Heartbeat is a no-op: a pre-existing cluster never expires.
+func (p *CurrentKubeconfigProvider) Heartbeat(_ context.Context, _ *LeasedCluster) error { return nil }
+
+// KindPerWorkerProvider creates one kind cluster per Ginkgo parallel
+// process, so a `ginkgo -p` run fans out across N independent clusters
+// instead of serializing on a single one.
+type KindPerWorkerProvider struct {
+	// NamePrefix is prepended to the Ginkgo parallel process number to
+	// build the kind cluster name.
+	NamePrefix string
+}
+
+// Acquire creates (or reuses, if already running) a kind cluster named
+// "<NamePrefix>-<parallel process number>" and returns its REST config.
+func (p *KindPerWorkerProvider) Acquire(ctx context.Context, _ ClusterSpec) (*LeasedCluster, error) {
+	name := fmt.Sprintf("%s-%d", p.NamePrefix, GinkgoParallelNode())
+
+	if err := exec.CommandContext(ctx, "kind", "get", "kubeconfig", "--name", name).Run(); err != nil {
+		// No existing cluster under this name: create one.
+		createCmd := exec.CommandContext(ctx, "kind", "create", "cluster", "--name", name)
+		var stderr bytes.Buffer
+		createCmd.Stderr = &stderr
+		if err := createCmd.Run(); err != nil {
+			return nil, fmt.Errorf("while creating kind cluster %s: %w (stderr: %s)", name, err, stderr.String())
+		}
+	}
+
+	kubeconfigCmd := exec.CommandContext(ctx, "kind", "get", "kubeconfig", "--name", name)
+	kubeconfigBytes, err := kubeconfigCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("while reading kubeconfig for kind cluster %s: %w", name, err)
+	}
+
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfigBytes)
+	if err != nil {
+		return nil, fmt.Errorf("while parsing kubeconfig for kind cluster %s: %w", name, err)
+	}
+
+	return &LeasedCluster{Config: config, Kubeconfig: kubeconfigBytes, leaseName: name}, nil
+}
+
+// Release deletes the kind cluster backing leased.
+func (p *KindPerWorkerProvider) Release(ctx context.Context, leased *LeasedCluster) error {
+	if leased == nil || leased.leaseName == "" {
+		return nil
+	}
+	_, err := exec.CommandContext(ctx, "kind", "delete", "cluster", "--name", leased.leaseName).CombinedOutput()
+	return err
+}
+
+// Heartbeat is a no-op: a locally running kind cluster never expires.
+func (p *KindPerWorkerProvider) Heartbeat(_ context.Context, _ *LeasedCluster) error { return nil }
+
+// BoskosLeaseClient acquires clusters from a Boskos-style resource-leasing
+// server over HTTP, using its acquire/release/update endpoints, and keeps
+// the lease alive with a background heartbeat goroutine started by Acquire
+// and stopped by Release.
+type BoskosLeaseClient struct {
+	// URL is the base URL of the Boskos server, e.g. "http://boskos.default.svc.cluster.local".
+	URL string
+
+	// Owner identifies this client to the Boskos server.
+	Owner string
+
+	// HeartbeatInterval is how often Acquire's background goroutine calls
+	// the update endpoint to keep the lease from expiring. Defaults to one
+	// minute when zero.
+	HeartbeatInterval time.Duration
+
+	// HTTPClient is used for every request. Defaults to http.DefaultClient
+	// when nil.
+	HTTPClient *http.Client
+
+	mu sync.Mutex
+}
+
+type boskosResource struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	State string `json:"state"`
+}
+
+func (c *BoskosLeaseClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *BoskosLeaseClient) heartbeatInterval() time.Duration {
+	if c.HeartbeatInterval > 0 {
+		return c.HeartbeatInterval
+	}
+	return time.Minute
+}
+
+// Acquire requests a resource of spec.ResourceType from Boskos, blocking
+// the caller only for the single acquire call (Boskos itself blocks
+// server-side until a resource is free or the request times out), then
+// starts a background goroutine heartbeating the lease until Release is
+// called.
+func (c *BoskosLeaseClient) Acquire(ctx context.Context, spec ClusterSpec) (*LeasedCluster, error) {
+	var resource boskosResource
+	if err := c.call(ctx, "GET", fmt.Sprintf(
+		"/acquire?type=%s&state=free&dest=busy&owner=%s", spec.ResourceType, c.Owner), nil, &resource); err != nil {
+		return nil, fmt.Errorf("while acquiring a %s lease from boskos: %w", spec.ResourceType, err)
+	}
+
+	config, kubeconfig, err := c.kubeconfigFor(ctx, resource.Name)
+	if err != nil {
+		_ = c.call(ctx, "POST", fmt.Sprintf(
+			"/release?name=%s&dest=dirty&owner=%s", resource.Name, c.Owner), nil, nil)
+		return nil, err
+	}
+
+	leased := &LeasedCluster{
+		Config:        config,
+		Kubeconfig:    kubeconfig,
+		leaseName:     resource.Name,
+		stopHeartbeat: make(chan struct{}),
+	}
+
+	// stop is captured once here rather than read from leased.stopHeartbeat
+	// on every loop iteration: Release closes and nils that field under
+	// c.mu from another goroutine, and selecting on a field with no lock
+	// at all is a data race even though the close/receive themselves are
+	// safe operations.
+	stop := leased.stopHeartbeat
+	go func() {
+		defer GinkgoRecover()
+		ticker := time.NewTicker(c.heartbeatInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = c.Heartbeat(ctx, leased)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return leased, nil
+}
+
+// Heartbeat calls Boskos' update endpoint to keep leased from expiring.
+func (c *BoskosLeaseClient) Heartbeat(ctx context.Context, leased *LeasedCluster) error {
+	if leased == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	name := leased.leaseName
+	c.mu.Unlock()
+	if name == "" {
+		return nil
+	}
+
+	return c.call(ctx, "POST", fmt.Sprintf(
+		"/update?name=%s&state=busy&owner=%s", name, c.Owner), nil, nil)
+}
+
+// Release returns leased to Boskos and stops its heartbeat goroutine. It
+// is safe to call more than once, and safe to call from a deferred,
+// recovered panic handler during suite teardown, since it only touches
+// leased's own fields and swallows a nil/already-released leased.
+func (c *BoskosLeaseClient) Release(ctx context.Context, leased *LeasedCluster) error {
+	if leased == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	name := leased.leaseName
+	if name == "" {
+		c.mu.Unlock()
+		return nil
+	}
+	if leased.stopHeartbeat != nil {
+		close(leased.stopHeartbeat)
+		leased.stopHeartbeat = nil
+	}
+	// Mark the lease consumed regardless of what the release call below
+	// returns, so a second Release call (e.g. from both a deferred recover
+	// and a normal AfterEach) is a harmless no-op instead of leasing the
+	// same name twice. This also happens under c.mu, the same lock
+	// Heartbeat takes to read leaseName, closing the window where the
+	// heartbeat goroutine could still be mid-read of the field being
+	// cleared here.
+	leased.leaseName = ""
+	c.mu.Unlock()
+
+	return c.call(ctx, "POST", fmt.Sprintf(
+		"/release?name=%s&dest=dirty&owner=%s", name, c.Owner), nil, nil)
+}
+
+// kubeconfigFor asks Boskos for the user-data it stored for resourceName,
+// which this suite expects to hold a "kubeconfig" key with the leased
+// cluster's kubeconfig contents.
+func (c *BoskosLeaseClient) kubeconfigFor(ctx context.Context, resourceName string) (*rest.Config, []byte, error) {
+	var userData map[string]string
+	if err := c.call(ctx, "GET", fmt.Sprintf("/resource/%s", resourceName), nil, &userData); err != nil {
+		return nil, nil, fmt.Errorf("while reading boskos user-data for %s: %w", resourceName, err)
+	}
+
+	kubeconfig, ok := userData["kubeconfig"]
+	if !ok {
+		return nil, nil, fmt.Errorf("boskos resource %s has no kubeconfig user-data", resourceName)
+	}
+
+	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(kubeconfig))
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, []byte(kubeconfig), nil
+}
+
+func (c *BoskosLeaseClient) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var bodyReader *bytes.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		bodyReader = bytes.NewReader(encoded)
+	} else {
+		bodyReader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, bodyReader)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("boskos %s %s returned status %d", method, path, resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
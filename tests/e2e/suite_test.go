@@ -0,0 +1,61 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package e2e
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	testsUtils "github.com/EnterpriseDB/cloud-native-postgresql/tests/utils"
+)
+
+var (
+	env                  *testsUtils.TestingEnvironment
+	leasedCluster        *LeasedCluster
+	suiteClusterProvider ClusterProvider
+)
+
+func TestE2E(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "E2E Suite")
+}
+
+var _ = BeforeSuite(func() {
+	suiteClusterProvider = NewClusterProviderFromEnv()
+
+	var err error
+	leasedCluster, err = suiteClusterProvider.Acquire(context.Background(), ClusterSpecFromEnv("e2e"))
+	Expect(err).ToNot(HaveOccurred())
+
+	// CurrentKubeconfigProvider leaves Kubeconfig empty: the process'
+	// environment already points at the right kubeconfig, so there's
+	// nothing to override.
+	if len(leasedCluster.Kubeconfig) > 0 {
+		kubeconfigFile, err := os.CreateTemp("", "e2e-kubeconfig-*.yaml")
+		Expect(err).ToNot(HaveOccurred())
+		defer kubeconfigFile.Close()
+
+		_, err = kubeconfigFile.Write(leasedCluster.Kubeconfig)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.Setenv("KUBECONFIG", kubeconfigFile.Name())).To(Succeed())
+	}
+
+	env, err = testsUtils.NewTestingEnvironment()
+	Expect(err).ToNot(HaveOccurred())
+})
+
+var _ = AfterSuite(func() {
+	if suiteClusterProvider == nil || leasedCluster == nil {
+		return
+	}
+	Expect(suiteClusterProvider.Release(context.Background(), leasedCluster)).To(Succeed())
+})
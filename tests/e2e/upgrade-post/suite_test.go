@@ -0,0 +1,32 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+// Package upgradepost is the standalone "after the operator upgrade" half of
+// the Upgrade e2e suite, see tests/e2e/upgrade-pre for the half that seeds
+// the state this suite verifies.
+package upgradepost
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	testsUtils "github.com/EnterpriseDB/cloud-native-postgresql/tests/utils"
+)
+
+var env *testsUtils.TestingEnvironment
+
+func TestUpgradePost(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Upgrade Post-Suite")
+}
+
+var _ = BeforeSuite(func() {
+	var err error
+	env, err = testsUtils.NewTestingEnvironment()
+	Expect(err).ToNot(HaveOccurred())
+})
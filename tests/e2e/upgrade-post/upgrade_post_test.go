@@ -0,0 +1,78 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package upgradepost
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/EnterpriseDB/cloud-native-postgresql/tests"
+	"github.com/EnterpriseDB/cloud-native-postgresql/tests/e2e/upgradeshared"
+)
+
+const (
+	fixturesDir = "../fixtures"
+
+	upgradeNamespace = "upgrade-e2e"
+
+	clusterName1   = "cluster1"
+	updateConfFile = fixturesDir + "/upgrade/conf-update.yaml"
+
+	clusterName2 = "cluster2"
+	sampleFile2  = fixturesDir + "/upgrade/cluster2.yaml"
+
+	minioClientName    = "mc"
+	restoreFile        = fixturesDir + "/upgrade/cluster-restore.yaml"
+	countBackupsScript = "sh -c 'mc find minio --name data.tar.gz | wc -l'"
+)
+
+// This suite assumes an operator has already been upgraded on top of the
+// state seeded by tests/e2e/upgrade-pre: a Cluster, a Backup and a
+// ScheduledBackup built with the previous operator version. It verifies that
+// state survived, then tears the namespace down since it's the last stage of
+// the upgrade path.
+var _ = Describe("Upgrade post-upgrade verification", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), func() {
+	AfterEach(func() {
+		err := env.DeleteNamespace(upgradeNamespace)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("confirms the pre-upgrade Cluster, Backup and ScheduledBackup still work", func() {
+		By("having the pre-upgrade Cluster ready again", func() {
+			upgradeshared.AssertClusterIsReady(upgradeNamespace, clusterName1, 300, env)
+		})
+
+		upgradeshared.AssertConfUpgrade(upgradeNamespace, clusterName1, updateConfFile, env)
+
+		By("installing a second Cluster on the upgraded operator", func() {
+			_, _, err := tests.Run("kubectl create -n " + upgradeNamespace + " -f " + sampleFile2)
+			Expect(err).ToNot(HaveOccurred())
+
+			upgradeshared.AssertClusterIsReady(upgradeNamespace, clusterName2, 600, env)
+		})
+
+		By("restoring the backup taken before the upgrade in a new cluster", func() {
+			restoredClusterName := "cluster-restore"
+			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", upgradeNamespace, restoreFile))
+			Expect(err).ToNot(HaveOccurred())
+
+			upgradeshared.AssertClusterIsReady(upgradeNamespace, restoredClusterName, 800, env)
+
+			primary := restoredClusterName + "-1"
+			cmd := "psql -U postgres appdb -tAc 'SELECT count(*) FROM to_restore'"
+			out, _, err := tests.Run(fmt.Sprintf("kubectl exec -n %v %v -- %v", upgradeNamespace, primary, cmd))
+			Expect(strings.Trim(out, "\n"), err).To(BeEquivalentTo("2"))
+		})
+
+		By("verifying scheduled backups are still happening", func() {
+			upgradeshared.AssertScheduledBackupsAreScheduled(upgradeNamespace, minioClientName, countBackupsScript)
+		})
+	})
+})
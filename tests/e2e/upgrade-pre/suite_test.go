@@ -0,0 +1,37 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+// Package upgradepre is the standalone "before the operator upgrade" half of
+// the Upgrade e2e suite (tests/e2e/upgrade_test.go). It seeds a Cluster, a
+// Backup and a ScheduledBackup built with whatever operator is currently
+// installed, then exits without tearing anything down: the companion
+// upgrade-post suite picks the objects back up from the cluster after the
+// operator has been upgraded. The two binaries only share state through
+// Kubernetes objects, so they can be invoked independently, see the
+// test-upgrade-e2e Makefile target.
+package upgradepre
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	testsUtils "github.com/EnterpriseDB/cloud-native-postgresql/tests/utils"
+)
+
+var env *testsUtils.TestingEnvironment
+
+func TestUpgradePre(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Upgrade Pre-Suite")
+}
+
+var _ = BeforeSuite(func() {
+	var err error
+	env, err = testsUtils.NewTestingEnvironment()
+	Expect(err).ToNot(HaveOccurred())
+})
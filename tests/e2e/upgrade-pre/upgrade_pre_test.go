@@ -0,0 +1,159 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package upgradepre
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apiv1 "github.com/EnterpriseDB/cloud-native-postgresql/api/v1"
+	"github.com/EnterpriseDB/cloud-native-postgresql/pkg/utils"
+	"github.com/EnterpriseDB/cloud-native-postgresql/tests"
+	"github.com/EnterpriseDB/cloud-native-postgresql/tests/e2e/upgradeshared"
+)
+
+const (
+	fixturesDir = "../fixtures"
+
+	upgradeNamespace = "upgrade-e2e"
+
+	pgSecrets   = fixturesDir + "/upgrade/pgsecrets.yaml" //nolint:gosec
+	minioSecret = fixturesDir + "/upgrade/minio-secret.yaml"
+
+	clusterName1 = "cluster1"
+	sampleFile   = fixturesDir + "/upgrade/cluster1.yaml"
+
+	minioPVCFile        = fixturesDir + "/upgrade/minio-pvc.yaml"
+	minioDeploymentFile = fixturesDir + "/upgrade/minio-deployment.yaml"
+	serviceFile         = fixturesDir + "/upgrade/minio-service.yaml"
+	clientFile          = fixturesDir + "/upgrade/minio-client.yaml"
+	minioClientName     = "mc"
+
+	backupName          = "cluster-backup"
+	backupFile          = fixturesDir + "/upgrade/backup1.yaml"
+	scheduledBackupFile = fixturesDir + "/upgrade/scheduled-backup.yaml"
+)
+
+// This suite only seeds the pre-upgrade state. It is meant to run against
+// the last released operator manifest, right before the locally built
+// manifest is applied on top of it by `kubectl apply` and the upgrade-post
+// suite takes over.
+var _ = Describe("Upgrade pre-upgrade seeding", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), func() {
+	It("seeds a Cluster, a Backup and a ScheduledBackup for the post-upgrade suite to verify", func() {
+		By(fmt.Sprintf("having a '%s' upgradeNamespace", upgradeNamespace), func() {
+			err := env.CreateNamespace(upgradeNamespace)
+			Expect(err).ToNot(HaveOccurred())
+
+			namespacedName := types.NamespacedName{Namespace: upgradeNamespace, Name: upgradeNamespace}
+			Eventually(func() (string, error) {
+				namespaceResource := &corev1.Namespace{}
+				err := env.Client.Get(env.Ctx, namespacedName, namespaceResource)
+				return namespaceResource.GetName(), err
+			}, 20).Should(BeEquivalentTo(upgradeNamespace))
+		})
+
+		By("creating the postgres secrets", func() {
+			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", upgradeNamespace, pgSecrets))
+			Expect(err).ToNot(HaveOccurred())
+		})
+		By("creating the cloud storage credentials", func() {
+			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", upgradeNamespace, minioSecret))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		By(fmt.Sprintf("creating a Cluster in the '%v' upgradeNamespace", upgradeNamespace), func() {
+			Eventually(func() error {
+				_, stderr, err := tests.Run("kubectl create -n " + upgradeNamespace + " -f " + sampleFile)
+				if err != nil {
+					GinkgoWriter.Printf("stderr: %s\n", stderr)
+				}
+				return err
+			}, 120).ShouldNot(HaveOccurred())
+		})
+
+		By("creating minio resources", func() {
+			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", upgradeNamespace, minioPVCFile))
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", upgradeNamespace, minioDeploymentFile))
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", upgradeNamespace, clientFile))
+			Expect(err).ToNot(HaveOccurred())
+			_, _, err = tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", upgradeNamespace, serviceFile))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		By("having a Cluster with three instances ready", func() {
+			upgradeshared.AssertClusterIsReady(upgradeNamespace, clusterName1, 600, env)
+		})
+
+		By("having minio resources ready", func() {
+			deploymentNamespacedName := types.NamespacedName{Namespace: upgradeNamespace, Name: "minio"}
+			Eventually(func() (int32, error) {
+				deployment := &appsv1.Deployment{}
+				err := env.Client.Get(env.Ctx, deploymentNamespacedName, deployment)
+				return deployment.Status.ReadyReplicas, err
+			}, 300).Should(BeEquivalentTo(1))
+
+			mcNamespacedName := types.NamespacedName{Namespace: upgradeNamespace, Name: minioClientName}
+			Eventually(func() (bool, error) {
+				mc := &corev1.Pod{}
+				err := env.Client.Get(env.Ctx, mcNamespacedName, mc)
+				return utils.IsPodReady(*mc), err
+			}, 180).Should(BeTrue())
+		})
+
+		By("creating data on the database", func() {
+			primary := clusterName1 + "-1"
+			cmd := "psql -U postgres appdb -tAc 'CREATE TABLE to_restore AS VALUES (1), (2);'"
+			_, _, err := tests.Run(fmt.Sprintf("kubectl exec -n %v %v -- %v", upgradeNamespace, primary, cmd))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		By("archiving WALs on minio", func() {
+			primary := clusterName1 + "-1"
+			switchWalCmd := "psql -U postgres appdb -tAc 'CHECKPOINT; SELECT pg_walfile_name(pg_switch_wal())'"
+			out, _, err := tests.Run(fmt.Sprintf("kubectl exec -n %v %v -- %v", upgradeNamespace, primary, switchWalCmd))
+			Expect(err).ToNot(HaveOccurred())
+			latestWAL := strings.TrimSpace(out)
+
+			Eventually(func() (int, error, error) {
+				findCmd := fmt.Sprintf("sh -c 'mc find minio --name %v.gz | wc -l'", latestWAL)
+				out, _, err := tests.RunUnchecked(fmt.Sprintf(
+					"kubectl exec -n %v %v -- %v", upgradeNamespace, minioClientName, findCmd))
+				value, atoiErr := strconv.Atoi(strings.Trim(out, "\n"))
+				return value, err, atoiErr
+			}, 30).Should(BeEquivalentTo(1))
+		})
+
+		By("uploading a backup on minio", func() {
+			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", upgradeNamespace, backupFile))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		By("verifying that a backup has actually completed", func() {
+			backupNamespacedName := types.NamespacedName{Namespace: upgradeNamespace, Name: backupName}
+			Eventually(func() (apiv1.BackupPhase, error) {
+				backup := &apiv1.Backup{}
+				err := env.Client.Get(env.Ctx, backupNamespacedName, backup)
+				return backup.Status.Phase, err
+			}, 200).Should(BeEquivalentTo(apiv1.BackupPhaseCompleted))
+		})
+
+		By("creating a ScheduledBackup", func() {
+			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", upgradeNamespace, scheduledBackupFile))
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+})
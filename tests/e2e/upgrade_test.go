@@ -9,8 +9,10 @@ package e2e
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/thoas/go-funk"
@@ -28,8 +30,10 @@ import (
 	. "github.com/onsi/gomega"
 
 	apiv1 "github.com/EnterpriseDB/cloud-native-postgresql/api/v1"
+	"github.com/EnterpriseDB/cloud-native-postgresql/pkg/conversion"
 	"github.com/EnterpriseDB/cloud-native-postgresql/pkg/utils"
 	"github.com/EnterpriseDB/cloud-native-postgresql/tests"
+	"github.com/EnterpriseDB/cloud-native-postgresql/tests/e2e/upgradeshared"
 	testsUtils "github.com/EnterpriseDB/cloud-native-postgresql/tests/utils"
 )
 
@@ -54,8 +58,25 @@ var _ = Describe("Upgrade", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), O
 		configName          = "postgresql-operator-controller-manager-config"
 		operatorUpgradeFile = fixturesDir + "/upgrade/current-manifest.yaml"
 
-		rollingUpgradeNamespace = "rolling-upgrade"
-		onlineUpgradeNamespace  = "online-upgrade"
+		rollingUpgradeNamespace  = "rolling-upgrade"
+		onlineUpgradeNamespace   = "online-upgrade"
+		chainUpgradeNamespace    = "chain-upgrade"
+		scaleNamespacePrefix     = "upgrade-scale"
+		snapshotUpgradeNamespace = "snapshot-upgrade"
+
+		// upgradeScaleCountEnv, when set, overrides the number of parallel
+		// namespace/Cluster pairs provisioned by the scale variant of the
+		// upgrade test.
+		upgradeScaleCountEnv      = "UPGRADE_SCALE_COUNT"
+		defaultUpgradeScale       = 10
+		upgradeScaleRolloutBudget = 600
+
+		// upgradeChainTagsEnv, when set, is a comma separated list of release
+		// tags (oldest first) that the chain test will install and upgrade
+		// through in order, finishing on the current operator build. When
+		// unset, the chain degenerates to the single most recent release tag,
+		// matching the behaviour of the plain upgrade tests above.
+		upgradeChainTagsEnv = "UPGRADE_CHAIN_TAGS"
 
 		pgSecrets = fixturesDir + "/upgrade/pgsecrets.yaml" //nolint:gosec
 
@@ -78,9 +99,35 @@ var _ = Describe("Upgrade", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), O
 		backupName          = "cluster-backup"
 		backupFile          = fixturesDir + "/upgrade/backup1.yaml"
 		restoreFile         = fixturesDir + "/upgrade/cluster-restore.yaml"
+		scheduledBackupName = "cluster-scheduled-backup"
 		scheduledBackupFile = fixturesDir + "/upgrade/scheduled-backup.yaml"
+
+		// CRD names whose storedVersions are checked by
+		// assertConversionRoundTripIsLossless. The suite currently only
+		// ships a single API version, so drift here would mean the API
+		// server persisted an object under a version the operator no
+		// longer declares in its CRD.
+		clusterCRDName         = "clusters.postgresql.k8s.enterprisedb.io"
+		backupCRDName          = "backups.postgresql.k8s.enterprisedb.io"
+		scheduledBackupCRDName = "scheduledbackups.postgresql.k8s.enterprisedb.io"
+
+		// snapshotBackupName/snapshotBackupFile/snapshotRestoreFile exercise
+		// the Method: volumeSnapshot backup path, as opposed to the Barman
+		// object store path exercised by backupFile/restoreFile above.
+		snapshotBackupName  = "cluster-snapshot-backup"
+		snapshotBackupFile  = fixturesDir + "/upgrade/backup-snapshot.yaml"
+		snapshotRestoreFile = fixturesDir + "/upgrade/cluster-restore-snapshot.yaml"
 		countBackupsScript  = "sh -c 'mc find minio --name data.tar.gz | wc -l'"
 		level               = tests.Lowest
+
+		continuousWorkloadTable = "continuous_workload"
+
+		// Env vars letting CI tune the downtime SLO asserted by
+		// assertContinuousWorkloadSLO without recompiling the suite.
+		primaryGapThresholdEnv         = "UPGRADE_WORKLOAD_MAX_GAP_SECONDS"
+		defaultPrimaryGapThresholdSecs = 5
+		replicaLagThresholdEnv         = "UPGRADE_WORKLOAD_MAX_LAG_SECONDS"
+		defaultReplicaLagThresholdSecs = 10
 	)
 
 	var upgradeNamespace string
@@ -106,27 +153,7 @@ var _ = Describe("Upgrade", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), O
 	// This check relies on the fact that nothing is performing backups
 	// but a single scheduled backups during the check
 	AssertScheduledBackupsAreScheduled := func() {
-		By("verifying scheduled backups are still happening", func() {
-			out, _, err := tests.Run(fmt.Sprintf(
-				"kubectl exec -n %v %v -- %v",
-				upgradeNamespace,
-				minioClientName,
-				countBackupsScript))
-			Expect(err).ToNot(HaveOccurred())
-			currentBackups, err := strconv.Atoi(strings.Trim(out, "\n"))
-			Expect(err).ToNot(HaveOccurred())
-			Eventually(func() (int, error) {
-				out, _, err := tests.RunUnchecked(fmt.Sprintf(
-					"kubectl exec -n %v %v -- %v",
-					upgradeNamespace,
-					minioClientName,
-					countBackupsScript))
-				if err != nil {
-					return 0, err
-				}
-				return strconv.Atoi(strings.Trim(out, "\n"))
-			}, 120).Should(BeNumerically(">", currentBackups))
-		})
+		upgradeshared.AssertScheduledBackupsAreScheduled(upgradeNamespace, minioClientName, countBackupsScript)
 	}
 
 	AssertConfUpgrade := func(clusterName string, updateConfFile string) {
@@ -263,7 +290,71 @@ var _ = Describe("Upgrade", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), O
 		Expect(err).NotTo(HaveOccurred())
 	}
 
-	applyUpgrade := func(upgradeNamespace string) {
+	// getClusterPodUIDs returns the UIDs of every pod currently backing
+	// clusterName's instances, used to tell rolling upgrades (UIDs change)
+	// apart from in-place/online upgrades (UIDs are preserved).
+	getClusterPodUIDs := func(upgradeNamespace, clusterName string) ([]types.UID, error) {
+		var podUIDs []types.UID
+		podList, err := env.GetClusterPodList(upgradeNamespace, clusterName)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range podList.Items {
+			podUIDs = append(podUIDs, pod.GetUID())
+		}
+		return podUIDs, nil
+	}
+
+	// upgradeOperatorAndVerify runs install (which is expected to point the
+	// operator Deployment at a new version, be it a released tag or the
+	// locally built manifest) and then verifies that clusterName1 survived
+	// the hop: pods roll according to whether in-place updates are enabled,
+	// the Cluster becomes Ready again, and a configuration change can still
+	// be rolled out.
+	upgradeOperatorAndVerify := func(upgradeNamespace string, preUpgradePodUIDs []types.UID, install func()) {
+		install()
+
+		operatorConfigMapNamespacedName := types.NamespacedName{
+			Namespace: operatorNamespace,
+			Name:      configName,
+		}
+
+		// We need to check here if we were able to upgrade the cluster,
+		// be it rolling or online
+		// We look for the setting in the operator configMap
+		operatorConfigMap := &corev1.ConfigMap{}
+		err := env.Client.Get(env.Ctx, operatorConfigMapNamespacedName, operatorConfigMap)
+		if err != nil || operatorConfigMap.Data["ENABLE_INSTANCE_MANAGER_INPLACE_UPDATES"] == "false" {
+			// Wait for rolling update. We expect all the pods to change UID
+			Eventually(func() (int, error) {
+				currentUIDs, err := getClusterPodUIDs(upgradeNamespace, clusterName1)
+				if err != nil {
+					return 0, err
+				}
+				return len(funk.Join(currentUIDs, preUpgradePodUIDs, funk.InnerJoin).([]types.UID)), nil
+			}, 300).Should(BeEquivalentTo(0))
+		} else {
+			// Pods shouldn't change and there should be an event
+			assertManagerRollout()
+			Eventually(func() (int, error) {
+				currentUIDs, err := getClusterPodUIDs(upgradeNamespace, clusterName1)
+				if err != nil {
+					return 0, err
+				}
+				return len(funk.Join(currentUIDs, preUpgradePodUIDs, funk.InnerJoin).([]types.UID)), nil
+			}, 300).Should(BeEquivalentTo(3))
+		}
+		upgradeshared.AssertClusterIsReady(upgradeNamespace, clusterName1, 300, env)
+
+		AssertConfUpgrade(clusterName1, updateConfFile)
+	}
+
+	// seedPreUpgradeState creates the upgradeNamespace, a Cluster built with
+	// the previous operator version, a minio-backed object store, a Backup
+	// and a ScheduledBackup. This is the state every upgrade flavour
+	// (rolling, online, or a multi-hop chain) verifies survives the
+	// operator upgrade(s) that follow.
+	seedPreUpgradeState := func(upgradeNamespace string) {
 		By(fmt.Sprintf(
 			"having a '%s' upgradeNamespace",
 			upgradeNamespace), func() {
@@ -332,7 +423,7 @@ var _ = Describe("Upgrade", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), O
 		})
 
 		By("having a Cluster with three instances ready", func() {
-			AssertClusterIsReady(upgradeNamespace, clusterName1, 600, env)
+			upgradeshared.AssertClusterIsReady(upgradeNamespace, clusterName1, 600, env)
 		})
 
 		By("having minio resources ready", func() {
@@ -442,81 +533,121 @@ var _ = Describe("Upgrade", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), O
 			Expect(err).ToNot(HaveOccurred())
 		})
 		AssertScheduledBackupsAreScheduled()
+	}
 
-		var podUIDs []types.UID
-		podList, err := env.GetClusterPodList(namespace, clusterName1)
+	// captureConversionObjects snapshots the JSON representation of
+	// clusterName1, the pre-upgrade Backup and the pre-upgrade
+	// ScheduledBackup in ns, keyed by Kind. The result is later compared
+	// against a post-upgrade capture by assertConversionRoundTripIsLossless
+	// to catch a conversion webhook that silently drops fields or mutates
+	// defaults when it re-stores an old object at the current version.
+	captureConversionObjects := func(ns string) map[string][]byte {
+		snapshots := map[string][]byte{}
+
+		cluster := &apiv1.Cluster{}
+		Expect(env.Client.Get(env.Ctx, types.NamespacedName{Namespace: ns, Name: clusterName1}, cluster)).To(Succeed())
+		snapshot, err := conversion.Snapshot(cluster)
 		Expect(err).ToNot(HaveOccurred())
-		for _, pod := range podList.Items {
-			podUIDs = append(podUIDs, pod.GetUID())
-		}
+		snapshots[apiv1.ClusterKind] = snapshot
 
-		By("upgrading the operator to current version", func() {
-			timeout := 120
-			// Upgrade to the new version
-			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -f %v", operatorUpgradeFile))
-			Expect(err).NotTo(HaveOccurred())
-			// With the new deployment, a new pod should be started. When it's
-			// ready, the old one is removed. We wait for the number of replicas
-			// to decrease to 1.
-			Eventually(func() (int32, error) {
-				deployment, err := env.GetOperatorDeployment()
-				return deployment.Status.Replicas, err
-			}, timeout).Should(BeEquivalentTo(1))
-			// For a final check, we verify the pod is ready
-			Eventually(func() (int32, error) {
-				deployment, err := env.GetOperatorDeployment()
-				return deployment.Status.ReadyReplicas, err
-			}, timeout).Should(BeEquivalentTo(1))
+		backup := &apiv1.Backup{}
+		Expect(env.Client.Get(env.Ctx, types.NamespacedName{Namespace: ns, Name: backupName}, backup)).To(Succeed())
+		snapshot, err = conversion.Snapshot(backup)
+		Expect(err).ToNot(HaveOccurred())
+		snapshots[apiv1.BackupKind] = snapshot
+
+		scheduledBackup := &apiv1.ScheduledBackup{}
+		Expect(env.Client.Get(
+			env.Ctx, types.NamespacedName{Namespace: ns, Name: scheduledBackupName}, scheduledBackup)).To(Succeed())
+		snapshot, err = conversion.Snapshot(scheduledBackup)
+		Expect(err).ToNot(HaveOccurred())
+		snapshots[apiv1.ScheduledBackupKind] = snapshot
+
+		return snapshots
+	}
+
+	// assertConversionRoundTripIsLossless forces every object captured by a
+	// prior captureConversionObjects call to be re-encoded, then verifies
+	// that none of them lost or mutated a field across the round-trip and
+	// that the CRDs' storedVersions still match what this operator build
+	// declares.
+	//
+	// NOTE: with a single stored API version (v1), this re-encode is an
+	// identity conversion - it guards the re-encode/storedVersions
+	// bookkeeping in pkg/conversion, not a real ConvertTo/ConvertFrom
+	// webhook, since none exists yet. It is not a substitute for testing
+	// an actual multi-version conversion once one is introduced.
+	assertConversionRoundTripIsLossless := func(ns string, before map[string][]byte) {
+		By("forcing a re-encode of every object captured before the upgrade", func() {
+			cluster := &apiv1.Cluster{}
+			Expect(env.Client.Get(env.Ctx, types.NamespacedName{Namespace: ns, Name: clusterName1}, cluster)).To(Succeed())
+			Expect(conversion.ForceReEncode(env.Ctx, env.Client, cluster)).To(Succeed())
+
+			backup := &apiv1.Backup{}
+			Expect(env.Client.Get(env.Ctx, types.NamespacedName{Namespace: ns, Name: backupName}, backup)).To(Succeed())
+			Expect(conversion.ForceReEncode(env.Ctx, env.Client, backup)).To(Succeed())
+
+			scheduledBackup := &apiv1.ScheduledBackup{}
+			Expect(env.Client.Get(
+				env.Ctx, types.NamespacedName{Namespace: ns, Name: scheduledBackupName}, scheduledBackup)).To(Succeed())
+			Expect(conversion.ForceReEncode(env.Ctx, env.Client, scheduledBackup)).To(Succeed())
 		})
 
-		operatorConfigMapNamespacedName := types.NamespacedName{
-			Namespace: operatorNamespace,
-			Name:      configName,
-		}
+		By("verifying no object silently lost data across the conversion round-trip", func() {
+			after := captureConversionObjects(ns)
+			for kind, beforeSnapshot := range before {
+				diff, err := conversion.Diff(beforeSnapshot, after[kind])
+				Expect(err).ToNot(HaveOccurred())
+				Expect(diff).To(BeEmpty(), "unexpected conversion drift on %v: %v", kind, diff)
+			}
+		})
 
-		// We need to check here if we were able to upgrade the cluster,
-		// be it rolling or online
-		// We look for the setting in the operator configMap
-		operatorConfigMap := &corev1.ConfigMap{}
-		err = env.Client.Get(env.Ctx, operatorConfigMapNamespacedName, operatorConfigMap)
-		if err != nil || operatorConfigMap.Data["ENABLE_INSTANCE_MANAGER_INPLACE_UPDATES"] == "false" {
-			// Wait for rolling update. We expect all the pods to change UID
-			Eventually(func() (int, error) {
-				var currentUIDs []types.UID
-				currentPodList, err := env.GetClusterPodList(upgradeNamespace, clusterName1)
-				if err != nil {
-					return 0, err
-				}
-				for _, pod := range currentPodList.Items {
-					currentUIDs = append(currentUIDs, pod.GetUID())
-				}
-				return len(funk.Join(currentUIDs, podUIDs, funk.InnerJoin).([]types.UID)), nil
-			}, 300).Should(BeEquivalentTo(0))
-		} else {
-			// Pods shouldn't change and there should be an event
-			assertManagerRollout()
-			Eventually(func() (int, error) {
-				var currentUIDs []types.UID
-				currentPodList, err := env.GetClusterPodList(upgradeNamespace, clusterName1)
-				if err != nil {
-					return 0, err
-				}
-				for _, pod := range currentPodList.Items {
-					currentUIDs = append(currentUIDs, pod.GetUID())
-				}
-				return len(funk.Join(currentUIDs, podUIDs, funk.InnerJoin).([]types.UID)), nil
-			}, 300).Should(BeEquivalentTo(3))
-		}
-		AssertClusterIsReady(upgradeNamespace, clusterName1, 300, env)
+		By("verifying the CRD storedVersions didn't drift", func() {
+			expectedStoredVersions := []string{apiv1.GroupVersion.Version}
+			Expect(conversion.AssertStoredVersions(env.Ctx, env.Client, clusterCRDName, expectedStoredVersions)).To(Succeed())
+			Expect(conversion.AssertStoredVersions(env.Ctx, env.Client, backupCRDName, expectedStoredVersions)).To(Succeed())
+			Expect(conversion.AssertStoredVersions(
+				env.Ctx, env.Client, scheduledBackupCRDName, expectedStoredVersions)).To(Succeed())
+		})
+	}
 
-		AssertConfUpgrade(clusterName1, updateConfFile)
+	applyUpgrade := func(upgradeNamespace string) {
+		seedPreUpgradeState(upgradeNamespace)
+
+		podUIDs, err := getClusterPodUIDs(upgradeNamespace, clusterName1)
+		Expect(err).ToNot(HaveOccurred())
+
+		preUpgradeSnapshots := captureConversionObjects(upgradeNamespace)
+
+		upgradeOperatorAndVerify(upgradeNamespace, podUIDs, func() {
+			By("upgrading the operator to current version", func() {
+				timeout := 120
+				// Upgrade to the new version
+				_, _, err := tests.Run(fmt.Sprintf("kubectl apply -f %v", operatorUpgradeFile))
+				Expect(err).NotTo(HaveOccurred())
+				// With the new deployment, a new pod should be started. When it's
+				// ready, the old one is removed. We wait for the number of replicas
+				// to decrease to 1.
+				Eventually(func() (int32, error) {
+					deployment, err := env.GetOperatorDeployment()
+					return deployment.Status.Replicas, err
+				}, timeout).Should(BeEquivalentTo(1))
+				// For a final check, we verify the pod is ready
+				Eventually(func() (int32, error) {
+					deployment, err := env.GetOperatorDeployment()
+					return deployment.Status.ReadyReplicas, err
+				}, timeout).Should(BeEquivalentTo(1))
+			})
+		})
+
+		assertConversionRoundTripIsLossless(upgradeNamespace, preUpgradeSnapshots)
 
 		By("installing a second Cluster on the upgraded operator", func() {
 			_, _, err := tests.Run(
 				"kubectl create -n " + upgradeNamespace + " -f " + sampleFile2)
 			Expect(err).ToNot(HaveOccurred())
 
-			AssertClusterIsReady(upgradeNamespace, clusterName2, 600, env)
+			upgradeshared.AssertClusterIsReady(upgradeNamespace, clusterName2, 600, env)
 		})
 
 		AssertConfUpgrade(clusterName2, updateConfFile2)
@@ -530,7 +661,7 @@ var _ = Describe("Upgrade", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), O
 				upgradeNamespace, restoreFile))
 			Expect(err).ToNot(HaveOccurred())
 
-			AssertClusterIsReady(upgradeNamespace, restoredClusterName, 800, env)
+			upgradeshared.AssertClusterIsReady(upgradeNamespace, restoredClusterName, 800, env)
 
 			// Test data should be present on restored primary
 			primary := restoredClusterName + "-1"
@@ -570,6 +701,301 @@ var _ = Describe("Upgrade", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), O
 		AssertScheduledBackupsAreScheduled()
 	}
 
+	// assertBackupIsStillUsable checks that the Backup taken from clusterName1
+	// before the upgrade chain started can still bootstrap a brand new
+	// Cluster on whatever operator version is currently installed.
+	assertBackupIsStillUsable := func(restoredClusterName string) {
+		By(fmt.Sprintf("restoring the pre-upgrade backup as '%v'", restoredClusterName), func() {
+			restoreFixture, _, err := tests.Run(fmt.Sprintf(
+				"sed -e 's/cluster-restore/%v/' %v", restoredClusterName, restoreFile))
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() error {
+				_, _, err := tests.RunUnchecked(fmt.Sprintf(
+					"echo '%v' | kubectl apply -n %v -f -", restoreFixture, upgradeNamespace))
+				return err
+			}, 120).ShouldNot(HaveOccurred())
+
+			upgradeshared.AssertClusterIsReady(upgradeNamespace, restoredClusterName, 800, env)
+
+			primary := restoredClusterName + "-1"
+			cmd := "psql -U postgres appdb -tAc 'SELECT count(*) FROM to_restore'"
+			out, _, err := tests.Run(fmt.Sprintf(
+				"kubectl exec -n %v %v -- %v",
+				upgradeNamespace,
+				primary,
+				cmd))
+			Expect(strings.Trim(out, "\n"), err).To(BeEquivalentTo("2"))
+
+			_, _, err = tests.RunUnchecked(fmt.Sprintf(
+				"kubectl delete cluster -n %v %v", upgradeNamespace, restoredClusterName))
+			Expect(err).ToNot(HaveOccurred())
+		})
+	}
+
+	// assertVolumeSnapshotBackupSurvivesUpgrade takes a Method: volumeSnapshot
+	// Backup of clusterName1, to be called before the operator upgrade, and
+	// waits for it to reach the completed phase. The post-upgrade checks on
+	// that Backup are made by assertVolumeSnapshotBackupIsRestorable.
+	assertVolumeSnapshotBackupSurvivesUpgrade := func() {
+		By("taking a volumeSnapshot backup of the first Cluster", func() {
+			_, _, err := tests.Run(fmt.Sprintf(
+				"kubectl apply -n %v -f %v",
+				upgradeNamespace, snapshotBackupFile))
+			Expect(err).ToNot(HaveOccurred())
+
+			backupNamespacedName := types.NamespacedName{
+				Namespace: upgradeNamespace,
+				Name:      snapshotBackupName,
+			}
+			Eventually(func() (apiv1.BackupPhase, error) {
+				backup := &apiv1.Backup{}
+				err := env.Client.Get(env.Ctx, backupNamespacedName, backup)
+				return backup.Status.Phase, err
+			}, 200).Should(BeEquivalentTo(apiv1.BackupPhaseCompleted))
+		})
+	}
+
+	// assertVolumeSnapshotBackupIsRestorable verifies the invariants that
+	// must survive an operator upgrade for a volumeSnapshot Backup: the
+	// stored object keeps reporting a usable content handle, and it can
+	// still bootstrap a working Cluster.
+	assertVolumeSnapshotBackupIsRestorable := func(restoredClusterName string) {
+		By("verifying the volumeSnapshot backup is readable after the upgrade", func() {
+			backup := &apiv1.Backup{}
+			err := env.Client.Get(env.Ctx, types.NamespacedName{
+				Namespace: upgradeNamespace,
+				Name:      snapshotBackupName,
+			}, backup)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(backup.IsCompletedVolumeSnapshot()).To(BeTrue())
+		})
+
+		By(fmt.Sprintf("restoring the volumeSnapshot backup as '%v'", restoredClusterName), func() {
+			restoreFixture, _, err := tests.Run(fmt.Sprintf(
+				"sed -e 's/cluster-restore-snapshot/%v/' %v", restoredClusterName, snapshotRestoreFile))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, _, err = tests.RunUnchecked(fmt.Sprintf(
+				"echo '%v' | kubectl apply -n %v -f -", restoreFixture, upgradeNamespace))
+			Expect(err).ToNot(HaveOccurred())
+
+			upgradeshared.AssertClusterIsReady(upgradeNamespace, restoredClusterName, 800, env)
+
+			primary := restoredClusterName + "-1"
+			cmd := "psql -U postgres appdb -tAc 'SELECT count(*) FROM to_restore'"
+			out, _, err := tests.Run(fmt.Sprintf(
+				"kubectl exec -n %v %v -- %v",
+				upgradeNamespace,
+				primary,
+				cmd))
+			Expect(strings.Trim(out, "\n"), err).To(BeEquivalentTo("2"))
+
+			// Restored standbys should soon attach themselves to the
+			// restored primary, exactly as for the object-store backup path.
+			Eventually(func() (string, error) {
+				cmd = "psql -U postgres appdb -tAc 'SELECT count(*) FROM pg_stat_replication'"
+				out, _, err = tests.Run(fmt.Sprintf(
+					"kubectl exec -n %v %v -- %v",
+					upgradeNamespace,
+					primary,
+					cmd))
+				return strings.Trim(out, "\n"), err
+			}, 180).Should(BeEquivalentTo("2"))
+		})
+	}
+
+	// getUpgradeChainTags returns the ordered list of release tags the chain
+	// test should hop through before landing on the current operator build.
+	// It defaults to a single hop from the most recent release, so the chain
+	// test behaves like the plain upgrade test unless CI opts in to a longer
+	// chain via UPGRADE_CHAIN_TAGS (e.g. "1.15.0,1.16.0,1.17.0").
+	getUpgradeChainTags := func() []string {
+		if rawTags, ok := os.LookupEnv(upgradeChainTagsEnv); ok && strings.TrimSpace(rawTags) != "" {
+			var tags []string
+			for _, tag := range strings.Split(rawTags, ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			return tags
+		}
+
+		mostRecentTag, err := testsUtils.GetMostRecentReleaseTag("../../releases")
+		Expect(err).NotTo(HaveOccurred())
+		return []string{mostRecentTag}
+	}
+
+	// applyUpgradeChain seeds the pre-upgrade state once, then walks the
+	// operator through each intermediate release tag in chainTags before
+	// finally upgrading to the current, locally built manifest. At every hop
+	// it re-verifies the existing Cluster, the pre-upgrade Backup and the
+	// ScheduledBackup, catching migration bugs that only show up when CRD or
+	// storage conversions are skipped across several releases at once.
+	applyUpgradeChain := func(upgradeNamespace string, chainTags []string) {
+		GinkgoWriter.Printf("installing the first tag of the chain: %s\n", chainTags[0])
+		installLatestCNPOperator(chainTags[0])
+
+		seedPreUpgradeState(upgradeNamespace)
+
+		podUIDs, err := getClusterPodUIDs(upgradeNamespace, clusterName1)
+		Expect(err).ToNot(HaveOccurred())
+
+		for hop, tag := range chainTags[1:] {
+			tag := tag
+			By(fmt.Sprintf("hopping to release tag %v (%v/%v)", tag, hop+2, len(chainTags)), func() {
+				upgradeOperatorAndVerify(upgradeNamespace, podUIDs, func() {
+					installLatestCNPOperator(tag)
+				})
+				assertBackupIsStillUsable(fmt.Sprintf("cluster-restore-hop-%d", hop+2))
+				AssertScheduledBackupsAreScheduled()
+
+				podUIDs, err = getClusterPodUIDs(upgradeNamespace, clusterName1)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		}
+
+		By("finally upgrading to the current, locally built operator", func() {
+			upgradeOperatorAndVerify(upgradeNamespace, podUIDs, func() {
+				_, _, err := tests.Run(fmt.Sprintf("kubectl apply -f %v", operatorUpgradeFile))
+				Expect(err).NotTo(HaveOccurred())
+			})
+			assertBackupIsStillUsable("cluster-restore-final")
+			AssertScheduledBackupsAreScheduled()
+		})
+	}
+
+	// getUpgradeScaleCount returns how many namespace/Cluster pairs the scale
+	// variant of the upgrade test should provision, defaulting to 10 unless
+	// CI opts into a different fan-out via UPGRADE_SCALE_COUNT.
+	getUpgradeScaleCount := func() int {
+		if raw, ok := os.LookupEnv(upgradeScaleCountEnv); ok {
+			if value, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && value > 0 {
+				return value
+			}
+		}
+		return defaultUpgradeScale
+	}
+
+	// seedScaleCluster provisions one namespace's worth of the scale test
+	// fixture: a Cluster, its own minio-backed object store, distinct data,
+	// a Backup and a ScheduledBackup. It is safe to call concurrently across
+	// namespaces since every step is namespace-scoped.
+	seedScaleCluster := func(ns string) {
+		Expect(env.CreateNamespace(ns)).To(Succeed())
+
+		for _, f := range []string{pgSecrets, minioSecret} {
+			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", ns, f))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Eventually(func() error {
+			_, stderr, err := tests.Run("kubectl create -n " + ns + " -f " + sampleFile)
+			if err != nil {
+				GinkgoWriter.Printf("stderr: %s\n", stderr)
+			}
+			return err
+		}, 120).ShouldNot(HaveOccurred())
+
+		for _, f := range []string{minioPVCFile, minioDeploymentFile, clientFile, serviceFile} {
+			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", ns, f))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		upgradeshared.AssertClusterIsReady(ns, clusterName1, upgradeScaleRolloutBudget, env)
+
+		Eventually(func() (int32, error) {
+			deployment := &appsv1.Deployment{}
+			err := env.Client.Get(env.Ctx, types.NamespacedName{Namespace: ns, Name: "minio"}, deployment)
+			return deployment.Status.ReadyReplicas, err
+		}, 300).Should(BeEquivalentTo(1))
+
+		primary := clusterName1 + "-1"
+		cmd := fmt.Sprintf("psql -U postgres appdb -tAc \"CREATE TABLE scale_data AS VALUES ('%v')\"", ns)
+		_, _, err := tests.Run(fmt.Sprintf("kubectl exec -n %v %v -- %v", ns, primary, cmd))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, _, err = tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", ns, backupFile))
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(func() (apiv1.BackupPhase, error) {
+			backup := &apiv1.Backup{}
+			err := env.Client.Get(env.Ctx, types.NamespacedName{Namespace: ns, Name: backupName}, backup)
+			return backup.Status.Phase, err
+		}, 200).Should(BeEquivalentTo(apiv1.BackupPhaseCompleted))
+
+		_, _, err = tests.Run(fmt.Sprintf("kubectl apply -n %v -f %v", ns, scheduledBackupFile))
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	// verifyScaleCluster re-checks a single namespace after the shared
+	// operator upgrade and returns the metrics reportScaleMetrics prints.
+	verifyScaleCluster := func(ns string) scaleClusterMetrics {
+		started := time.Now()
+		metric := scaleClusterMetrics{namespace: ns}
+
+		upgradeshared.AssertClusterIsReady(ns, clusterName1, upgradeScaleRolloutBudget, env)
+		metric.rolloutDuration = time.Since(started)
+
+		Eventually(func() error {
+			_, _, err := tests.RunUnchecked("kubectl apply -n " + ns + " -f " + updateConfFile)
+			return err
+		}, 60).ShouldNot(HaveOccurred())
+
+		namespacedName := types.NamespacedName{Namespace: ns, Name: clusterName1}
+		Eventually(func() (bool, error) {
+			cluster := &apiv1.Cluster{}
+			err := env.Client.Get(env.Ctx, namespacedName, cluster)
+			if err != nil {
+				metric.failedReconciles++
+				return false, err
+			}
+			return cluster.Status.CurrentPrimary == cluster.Status.TargetPrimary, nil
+		}, 300, "1s").Should(BeTrue())
+
+		out, _, err := tests.Run(fmt.Sprintf(
+			"kubectl exec -n %v %v -- %v", ns, minioClientName, countBackupsScript))
+		Expect(err).ToNot(HaveOccurred())
+		currentBackups, err := strconv.Atoi(strings.Trim(out, "\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(func() (int, error) {
+			out, _, err := tests.RunUnchecked(fmt.Sprintf(
+				"kubectl exec -n %v %v -- %v", ns, minioClientName, countBackupsScript))
+			if err != nil {
+				return 0, err
+			}
+			return strconv.Atoi(strings.Trim(out, "\n"))
+		}, 120).Should(BeNumerically(">", currentBackups))
+
+		return metric
+	}
+
+	// reportScaleMetrics summarizes the rollout duration distribution and
+	// the count of failed reconciles per cluster, surfacing scalability
+	// regressions the single-cluster upgrade tests can't catch.
+	reportScaleMetrics := func(metrics []scaleClusterMetrics) {
+		var total, min, max time.Duration
+		var totalFailedReconciles int
+		for i, m := range metrics {
+			if i == 0 || m.rolloutDuration < min {
+				min = m.rolloutDuration
+			}
+			if m.rolloutDuration > max {
+				max = m.rolloutDuration
+			}
+			total += m.rolloutDuration
+			totalFailedReconciles += m.failedReconciles
+			GinkgoWriter.Printf("scale upgrade: %s rollout duration=%s, %d failed reconciles\n",
+				m.namespace, m.rolloutDuration, m.failedReconciles)
+		}
+		var avg time.Duration
+		if len(metrics) > 0 {
+			avg = total / time.Duration(len(metrics))
+		}
+		GinkgoWriter.Printf(
+			"scale upgrade: %d clusters, rollout duration min=%s avg=%s max=%s, %d total failed reconciles\n",
+			len(metrics), min, avg, max, totalFailedReconciles)
+	}
+
 	It("works after an upgrade with rolling upgrade ", func() {
 		mostRecentTag, err := testsUtils.GetMostRecentReleaseTag("../../releases")
 		Expect(err).NotTo(HaveOccurred())
@@ -623,9 +1049,112 @@ var _ = Describe("Upgrade", Label(tests.LabelUpgrade, tests.LabelNoOpenshift), O
 
 		// set upgradeNamespace for log naming
 		upgradeNamespace = onlineUpgradeNamespace
+
+		stopWorkload, workloadStats := runContinuousWorkload(upgradeNamespace, clusterName1)
 		applyUpgrade(upgradeNamespace)
+		stopWorkload()
 
 		assertManagerRollout()
+
+		assertContinuousWorkloadSLO(upgradeNamespace, clusterName1, workloadStats)
+	})
+
+	It("works after a multi-hop upgrade chain", func() {
+		chainTags := getUpgradeChainTags()
+		GinkgoWriter.Printf("walking the upgrade chain: %v -> current\n", chainTags)
+
+		// set upgradeNamespace for log naming
+		upgradeNamespace = chainUpgradeNamespace
+		applyUpgradeChain(upgradeNamespace, chainTags)
+	})
+
+	It("works after an upgrade with N clusters in parallel", func() {
+		scaleCount := getUpgradeScaleCount()
+		namespaces := make([]string, scaleCount)
+		for i := range namespaces {
+			namespaces[i] = fmt.Sprintf("%v-%d", scaleNamespacePrefix, i)
+		}
+
+		// set upgradeNamespace for log naming; the namespaces actually used
+		// by this test are tracked (and torn down) explicitly below since
+		// there are many of them.
+		upgradeNamespace = namespaces[0]
+
+		mostRecentTag, err := testsUtils.GetMostRecentReleaseTag("../../releases")
+		Expect(err).NotTo(HaveOccurred())
+		installLatestCNPOperator(mostRecentTag)
+
+		By(fmt.Sprintf("seeding %d clusters in parallel", scaleCount), func() {
+			var wg sync.WaitGroup
+			wg.Add(len(namespaces))
+			for _, ns := range namespaces {
+				ns := ns
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					seedScaleCluster(ns)
+				}()
+			}
+			wg.Wait()
+		})
+
+		By("upgrading the operator once for every scale cluster", func() {
+			_, _, err := tests.Run(fmt.Sprintf("kubectl apply -f %v", operatorUpgradeFile))
+			Expect(err).NotTo(HaveOccurred())
+
+			timeout := 120
+			Eventually(func() (int32, error) {
+				deployment, err := env.GetOperatorDeployment()
+				return deployment.Status.ReadyReplicas, err
+			}, timeout).Should(BeEquivalentTo(1))
+		})
+
+		metrics := make([]scaleClusterMetrics, len(namespaces))
+		By(fmt.Sprintf("verifying all %d clusters survived the upgrade", scaleCount), func() {
+			var wg sync.WaitGroup
+			wg.Add(len(namespaces))
+			for i, ns := range namespaces {
+				i, ns := i, ns
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					metrics[i] = verifyScaleCluster(ns)
+				}()
+			}
+			wg.Wait()
+		})
+
+		reportScaleMetrics(metrics)
+
+		By("tearing down the scale namespaces", func() {
+			for _, ns := range namespaces {
+				Expect(env.DeleteNamespace(ns)).To(Succeed())
+			}
+		})
+	})
+
+	It("works after an upgrade using a CSI volume snapshot backup", func() {
+		upgradeNamespace = snapshotUpgradeNamespace
+		seedPreUpgradeState(upgradeNamespace)
+		assertVolumeSnapshotBackupSurvivesUpgrade()
+
+		podUIDs, err := getClusterPodUIDs(upgradeNamespace, clusterName1)
+		Expect(err).ToNot(HaveOccurred())
+
+		upgradeOperatorAndVerify(upgradeNamespace, podUIDs, func() {
+			By("upgrading the operator to current version", func() {
+				_, _, err := tests.Run(fmt.Sprintf("kubectl apply -f %v", operatorUpgradeFile))
+				Expect(err).NotTo(HaveOccurred())
+
+				timeout := 120
+				Eventually(func() (int32, error) {
+					deployment, err := env.GetOperatorDeployment()
+					return deployment.Status.ReadyReplicas, err
+				}, timeout).Should(BeEquivalentTo(1))
+			})
+		})
+
+		assertVolumeSnapshotBackupIsRestorable("cluster-restore-snapshot")
 	})
 })
 
@@ -658,6 +1187,216 @@ func enableOnlineUpgradeForInstanceManager(pgOperatorNamespace, configName strin
 	})
 }
 
+// scaleClusterMetrics is the per-namespace result of the parallel scale
+// variant of the upgrade test, collected into a report by reportScaleMetrics.
+type scaleClusterMetrics struct {
+	namespace        string
+	rolloutDuration  time.Duration
+	failedReconciles int
+}
+
+// continuousWorkloadStats accumulates the per-second observations made by
+// runContinuousWorkload's writer and reader goroutines. It is written to
+// concurrently by those goroutines, so every access goes through mu.
+type continuousWorkloadStats struct {
+	mu sync.Mutex
+
+	writeSuccesses int
+	writeErrors    int
+	gapStartedAt   time.Time
+	longestGap     time.Duration
+
+	readSuccesses     int
+	readErrors        int
+	connectionResets  int
+	longestReplicaLag time.Duration
+}
+
+func (s *continuousWorkloadStats) recordWrite(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.writeErrors++
+		if s.gapStartedAt.IsZero() {
+			s.gapStartedAt = time.Now()
+		}
+		return
+	}
+	s.writeSuccesses++
+	if !s.gapStartedAt.IsZero() {
+		if gap := time.Since(s.gapStartedAt); gap > s.longestGap {
+			s.longestGap = gap
+		}
+		s.gapStartedAt = time.Time{}
+	}
+}
+
+// finalizeOpenGap folds a still-open write gap into longestGap as of now,
+// so a writer that is down at the moment the workload is stopped still
+// counts toward the SLO instead of being silently dropped.
+func (s *continuousWorkloadStats) finalizeOpenGap() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.gapStartedAt.IsZero() {
+		return
+	}
+	if gap := time.Since(s.gapStartedAt); gap > s.longestGap {
+		s.longestGap = gap
+	}
+	s.gapStartedAt = time.Time{}
+}
+
+func (s *continuousWorkloadStats) recordRead(lag time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.readErrors++
+		if strings.Contains(err.Error(), "reset") || strings.Contains(err.Error(), "EOF") {
+			s.connectionResets++
+		}
+		return
+	}
+	s.readSuccesses++
+	if lag > s.longestReplicaLag {
+		s.longestReplicaLag = lag
+	}
+}
+
+// envDurationSeconds reads name as a whole number of seconds, falling back to
+// defaultSeconds when unset or unparsable.
+func envDurationSeconds(name string, defaultSeconds int) time.Duration {
+	if raw, ok := os.LookupEnv(name); ok {
+		if value, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil {
+			return time.Duration(value) * time.Second
+		}
+	}
+	return time.Duration(defaultSeconds) * time.Second
+}
+
+// isNotYetAvailable reports whether err looks like the target pod or table
+// doesn't exist yet, which is expected during the early seeding phase of the
+// upgrade test rather than a downtime event worth recording.
+func isNotYetAvailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "NotFound") ||
+		strings.Contains(msg, "not found") ||
+		strings.Contains(msg, "does not exist")
+}
+
+// runContinuousWorkload launches a background writer against clusterName's
+// primary (-rw) service and a background reader against its read-only (-ro)
+// service, issuing one statement per second each until the returned stop
+// function is called. It is meant to run for the full duration of an
+// operator upgrade, so assertContinuousWorkloadSLO can tell whether the
+// in-place instance-manager update path ever actually dropped connections.
+func runContinuousWorkload(upgradeNamespace, clusterName string) (stop func(), stats *continuousWorkloadStats) {
+	stats = &continuousWorkloadStats{}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	execPod := clusterName + "-1"
+	primaryService := clusterName + "-rw"
+	readOnlyService := clusterName + "-ro"
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		id := 0
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				id++
+				cmd := fmt.Sprintf(
+					"psql \"host=%v dbname=appdb user=postgres\" -tAc "+
+						"'CREATE TABLE IF NOT EXISTS %v (id int primary key, written_at timestamptz); "+
+						"INSERT INTO %v VALUES (%d, now())'",
+					primaryService, continuousWorkloadTable, continuousWorkloadTable, id)
+				_, _, err := tests.RunUnchecked(fmt.Sprintf(
+					"kubectl exec -n %v %v -- %v", upgradeNamespace, execPod, cmd))
+				if isNotYetAvailable(err) {
+					continue
+				}
+				stats.recordWrite(err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				cmd := fmt.Sprintf(
+					"psql \"host=%v dbname=appdb user=postgres\" -tAc "+
+						"'SELECT extract(epoch from now() - max(written_at)) FROM %v'",
+					readOnlyService, continuousWorkloadTable)
+				out, _, err := tests.RunUnchecked(fmt.Sprintf(
+					"kubectl exec -n %v %v -- %v", upgradeNamespace, execPod, cmd))
+				if isNotYetAvailable(err) {
+					continue
+				}
+				if err != nil {
+					stats.recordRead(0, err)
+					continue
+				}
+				lagSeconds, atoiErr := strconv.ParseFloat(strings.TrimSpace(out), 64)
+				stats.recordRead(time.Duration(lagSeconds*float64(time.Second)), atoiErr)
+			}
+		}
+	}()
+
+	stop = func() {
+		close(done)
+		wg.Wait()
+		stats.finalizeOpenGap()
+	}
+	return stop, stats
+}
+
+// assertContinuousWorkloadSLO fails the spec if the workload recorded by
+// runContinuousWorkload saw any committed-transaction loss, a primary
+// endpoint gap longer than the configured threshold, or a read-replica lag
+// above the configured threshold.
+func assertContinuousWorkloadSLO(upgradeNamespace, clusterName string, stats *continuousWorkloadStats) {
+	By("verifying the continuous workload observed no downtime SLO violations", func() {
+		maxGap := envDurationSeconds(primaryGapThresholdEnv, defaultPrimaryGapThresholdSecs)
+		maxLag := envDurationSeconds(replicaLagThresholdEnv, defaultReplicaLagThresholdSecs)
+
+		GinkgoWriter.Printf(
+			"workload: %d/%d writes ok, %d/%d reads ok, %d connection resets, "+
+				"longest primary gap %s, longest replica lag %s\n",
+			stats.writeSuccesses, stats.writeSuccesses+stats.writeErrors,
+			stats.readSuccesses, stats.readSuccesses+stats.readErrors,
+			stats.connectionResets, stats.longestGap, stats.longestReplicaLag)
+
+		Expect(stats.longestGap).To(BeNumerically("<=", maxGap),
+			"the primary endpoint should not be unreachable for longer than the configured threshold")
+		Expect(stats.longestReplicaLag).To(BeNumerically("<=", maxLag),
+			"the read-only endpoint should not lag behind the primary by more than the configured threshold")
+
+		// Zero committed-transaction loss: every row the writer believes it
+		// committed must still be present once the upgrade has completed.
+		out, _, err := tests.Run(fmt.Sprintf(
+			"kubectl exec -n %v %v-1 -- psql -U postgres appdb -tAc 'SELECT count(*) FROM %v'",
+			upgradeNamespace, clusterName, continuousWorkloadTable))
+		Expect(err).ToNot(HaveOccurred())
+		persistedRows, err := strconv.Atoi(strings.TrimSpace(out))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(persistedRows).To(BeNumerically(">=", stats.writeSuccesses),
+			"every write acknowledged by the primary should have survived the upgrade")
+	})
+}
+
 // install an operator version with the most recent release tag
 func installLatestCNPOperator(releaseTag string) {
 	mostRecentReleasePath := "../../releases/postgresql-operator-" + releaseTag + ".yaml"
@@ -673,30 +1412,11 @@ func installLatestCNPOperator(releaseTag string) {
 		return err
 	}, 60).ShouldNot(HaveOccurred())
 
-	Eventually(func() error {
-		_, _, err := tests.RunUnchecked(
-			"kubectl wait --for condition=established --timeout=60s " +
-				"crd/clusters.postgresql.k8s.enterprisedb.io")
-		return err
-	}, 150).ShouldNot(HaveOccurred())
-
-	Eventually(func() error {
-		mapping, err := env.Client.RESTMapper().RESTMapping(
-			schema.GroupKind{Group: apiv1.GroupVersion.Group, Kind: apiv1.ClusterKind},
-			apiv1.GroupVersion.Version)
-		if err != nil {
-			return err
-		}
-
-		GinkgoWriter.Printf("found mapping REST endpoint: %s\n", mapping.GroupVersionKind.String())
-
-		return nil
-	}, 150).ShouldNot(HaveOccurred())
-
-	Eventually(func() error {
-		_, _, err := tests.RunUnchecked(
-			"kubectl wait --for=condition=Available --timeout=2m -n postgresql-operator-system " +
-				"deployments postgresql-operator-controller-manager")
-		return err
-	}, 150).ShouldNot(HaveOccurred())
+	readiness := testsUtils.InstallationReadiness{
+		Client:      env.Client,
+		Namespace:   "postgresql-operator-system",
+		GVKs:        []schema.GroupVersionKind{apiv1.GroupVersion.WithKind(apiv1.ClusterKind)},
+		Deployments: []string{"postgresql-operator-controller-manager"},
+	}
+	Expect(readiness.Wait(env.Ctx, 150*time.Second)).To(Succeed())
 }
\ No newline at end of file
@@ -0,0 +1,92 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+// Package upgradeshared holds the setup and assertion helpers common to
+// every flavour of the upgrade e2e suite: the single-binary tests/e2e/upgrade_test.go
+// and the split tests/e2e/upgrade-pre and tests/e2e/upgrade-post pair. Keeping
+// them here means the assertion logic only needs to change in one place;
+// fixture paths and timeouts are still passed in by each caller.
+package upgradeshared
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apiv1 "github.com/EnterpriseDB/cloud-native-postgresql/api/v1"
+	"github.com/EnterpriseDB/cloud-native-postgresql/tests"
+	testsUtils "github.com/EnterpriseDB/cloud-native-postgresql/tests/utils"
+)
+
+// AssertClusterIsReady waits up to timeoutSeconds for clusterName in
+// namespace to report all 3 instances ready.
+func AssertClusterIsReady(namespace, clusterName string, timeoutSeconds int, env *testsUtils.TestingEnvironment) {
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: clusterName}
+	Eventually(func() (int, error) {
+		cluster := &apiv1.Cluster{}
+		err := env.Client.Get(env.Ctx, namespacedName, cluster)
+		if err != nil {
+			return 0, err
+		}
+		return cluster.Status.ReadyInstances, nil
+	}, timeoutSeconds).Should(BeEquivalentTo(3))
+}
+
+// AssertConfUpgrade applies updateConfFile against clusterName in namespace
+// and waits for the resulting switchover to complete.
+func AssertConfUpgrade(namespace, clusterName, updateConfFile string, env *testsUtils.TestingEnvironment) {
+	By("checking basic functionality performing a configuration upgrade on the cluster", func() {
+		namespacedName := types.NamespacedName{Namespace: namespace, Name: clusterName}
+		before := &apiv1.Cluster{}
+		Expect(env.Client.Get(env.Ctx, namespacedName, before)).To(Succeed())
+		oldPrimary := before.Status.CurrentPrimary
+		oldPrimaryTimestamp := before.Status.CurrentPrimaryTimestamp
+
+		Eventually(func() error {
+			_, _, err := tests.RunUnchecked("kubectl apply -n " + namespace + " -f " + updateConfFile)
+			return err
+		}, 60).ShouldNot(HaveOccurred())
+
+		Eventually(func() (bool, error) {
+			cluster := &apiv1.Cluster{}
+			err := env.Client.Get(env.Ctx, namespacedName, cluster)
+			if err != nil {
+				return false, err
+			}
+			switched := cluster.Status.CurrentPrimary != oldPrimary ||
+				cluster.Status.CurrentPrimaryTimestamp != oldPrimaryTimestamp
+			return switched && cluster.Status.CurrentPrimary == cluster.Status.TargetPrimary, nil
+		}, 300, "1s").Should(BeTrue())
+	})
+}
+
+// AssertScheduledBackupsAreScheduled checks that the number of backups
+// found by countBackupsScript on minioClientName is increasing, relying on
+// the fact that nothing but a single scheduled backup is running against
+// the bucket during the check.
+func AssertScheduledBackupsAreScheduled(namespace, minioClientName, countBackupsScript string) {
+	By("verifying scheduled backups are still happening", func() {
+		out, _, err := tests.Run(fmt.Sprintf(
+			"kubectl exec -n %v %v -- %v", namespace, minioClientName, countBackupsScript))
+		Expect(err).ToNot(HaveOccurred())
+		currentBackups, err := strconv.Atoi(strings.Trim(out, "\n"))
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(func() (int, error) {
+			out, _, err := tests.RunUnchecked(fmt.Sprintf(
+				"kubectl exec -n %v %v -- %v", namespace, minioClientName, countBackupsScript))
+			if err != nil {
+				return 0, err
+			}
+			return strconv.Atoi(strings.Trim(out, "\n"))
+		}, 120).Should(BeNumerically(">", currentBackups))
+	})
+}
@@ -0,0 +1,239 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/EnterpriseDB/cloud-native-postgresql/pkg/utils/gvkcheck"
+)
+
+// InstallationReadiness waits for every condition an operator install or
+// upgrade must satisfy before the e2e suite can safely start issuing
+// requests against its CRDs: each CRD accepted by the API server, the
+// RESTMapper actually exposing the resulting mapping, every operator
+// Deployment rolled out, webhook CA bundles populated, and (when declared)
+// the conversion webhook answering a probe. It replaces the ad-hoc
+// Eventually chains that used to be duplicated across the upgrade e2e
+// suite for this purpose.
+type InstallationReadiness struct {
+	// Client is used to read CRDs, Deployments and WebhookConfigurations,
+	// and to resolve GVKs through its RESTMapper.
+	Client client.Client
+
+	// Namespace is where the operator Deployments listed below live.
+	Namespace string
+
+	// GVKs is the set of Kinds whose backing CRD must report Established
+	// and NamesAccepted, and whose mapping must resolve through the
+	// RESTMapper, before installation is considered ready.
+	GVKs []schema.GroupVersionKind
+
+	// Deployments lists the operator Deployments that must reach
+	// Available with their observed generation caught up to Generation.
+	Deployments []string
+
+	// ValidatingWebhooks and MutatingWebhooks list the webhook
+	// configuration names whose CA bundles must be non-empty on every
+	// entry before the operator can be trusted to admit requests.
+	ValidatingWebhooks []string
+	MutatingWebhooks   []string
+}
+
+// Wait polls the conditions tracked by r one after the other, giving each
+// check its own timeout, so in the worst case Wait takes one timeout per
+// check. When a check times out it returns an error describing the last
+// diagnostics observed for that check, instead of a bare "timed out"
+// message.
+func (r *InstallationReadiness) Wait(ctx context.Context, timeout time.Duration) error {
+	const pollInterval = 2 * time.Second
+
+	checks := []struct {
+		name string
+		run  func(context.Context) (string, error)
+	}{
+		{"CRDs Established/NamesAccepted", r.checkCRDsEstablished},
+		{"RESTMapper visibility", r.checkRESTMapper},
+		{"operator Deployments Available", r.checkDeploymentsAvailable},
+		{"webhook CA bundles populated", r.checkWebhookCABundles},
+		{"GVK versions match what this operator expects", r.checkGVKVersions},
+	}
+
+	for _, check := range checks {
+		var lastDiagnostics string
+		var lastErr error
+
+		err := wait.PollImmediate(pollInterval, timeout, func() (bool, error) {
+			diagnostics, checkErr := check.run(ctx)
+			lastDiagnostics, lastErr = diagnostics, checkErr
+			return checkErr == nil, nil
+		})
+		if err != nil {
+			if lastErr != nil {
+				return fmt.Errorf(
+					"installation readiness check %q never succeeded: %w\nlast observed state:\n%s",
+					check.name, lastErr, lastDiagnostics)
+			}
+			return fmt.Errorf("installation readiness check %q never succeeded", check.name)
+		}
+	}
+	return nil
+}
+
+// checkCRDsEstablished verifies that the CRD backing every tracked GVK
+// reports both Established and NamesAccepted.
+func (r *InstallationReadiness) checkCRDsEstablished(ctx context.Context) (string, error) {
+	for _, gvk := range r.GVKs {
+		crdName, err := gvkcheck.CRDNameFor(r.Client, gvk)
+		if err != nil {
+			return fmt.Sprintf("could not resolve a CRD name for %s", gvk), err
+		}
+
+		var crd apiextensionsv1.CustomResourceDefinition
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: crdName}, &crd); err != nil {
+			return fmt.Sprintf("CRD %s not found", crdName), err
+		}
+
+		var established, namesAccepted bool
+		for _, cond := range crd.Status.Conditions {
+			if cond.Status != apiextensionsv1.ConditionTrue {
+				continue
+			}
+			switch cond.Type {
+			case apiextensionsv1.Established:
+				established = true
+			case apiextensionsv1.NamesAccepted:
+				namesAccepted = true
+			}
+		}
+		if !established || !namesAccepted {
+			return fmt.Sprintf("CRD %s conditions: %+v", crdName, crd.Status.Conditions),
+				fmt.Errorf("CRD %s is not yet Established/NamesAccepted", crdName)
+		}
+	}
+	return "", nil
+}
+
+// checkRESTMapper resets the client's RESTMapper, when it supports being
+// reset, and confirms every tracked GVK resolves afterward. Without this
+// reset a controller-runtime client can keep serving a stale mapping
+// cached from before the CRD existed.
+func (r *InstallationReadiness) checkRESTMapper(_ context.Context) (string, error) {
+	if resettable, ok := r.Client.RESTMapper().(meta.ResettableRESTMapper); ok {
+		resettable.Reset()
+	}
+
+	for _, gvk := range r.GVKs {
+		if _, err := r.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			return fmt.Sprintf("RESTMapper does not yet resolve %s", gvk), err
+		}
+	}
+	return "", nil
+}
+
+// checkDeploymentsAvailable verifies that every tracked Deployment reports
+// condition Available and has caught its ObservedGeneration up to its
+// current Generation, so a rollout still in flight isn't mistaken for a
+// completed one.
+func (r *InstallationReadiness) checkDeploymentsAvailable(ctx context.Context) (string, error) {
+	for _, name := range r.Deployments {
+		var deployment appsv1.Deployment
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: name}, &deployment); err != nil {
+			return fmt.Sprintf("Deployment %s/%s not found", r.Namespace, name), err
+		}
+
+		if deployment.Status.ObservedGeneration != deployment.Generation {
+			return fmt.Sprintf("Deployment %s/%s status: %+v", r.Namespace, name, deployment.Status),
+				fmt.Errorf("Deployment %s/%s has not caught up to generation %d yet",
+					r.Namespace, name, deployment.Generation)
+		}
+
+		available := false
+		for _, cond := range deployment.Status.Conditions {
+			if cond.Type == appsv1.DeploymentAvailable && cond.Status == corev1.ConditionTrue {
+				available = true
+			}
+		}
+		if !available {
+			return fmt.Sprintf("Deployment %s/%s conditions: %+v", r.Namespace, name, deployment.Status.Conditions),
+				fmt.Errorf("Deployment %s/%s is not yet Available", r.Namespace, name)
+		}
+	}
+	return "", nil
+}
+
+// checkWebhookCABundles verifies that every entry of every tracked
+// Validating/MutatingWebhookConfiguration carries a non-empty CA bundle,
+// which the webhook CA injector populates asynchronously after the
+// configuration object itself is created.
+func (r *InstallationReadiness) checkWebhookCABundles(ctx context.Context) (string, error) {
+	for _, name := range r.ValidatingWebhooks {
+		var config admissionregistrationv1.ValidatingWebhookConfiguration
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: name}, &config); err != nil {
+			return fmt.Sprintf("ValidatingWebhookConfiguration %s not found", name), err
+		}
+		for _, webhook := range config.Webhooks {
+			if len(webhook.ClientConfig.CABundle) == 0 {
+				return fmt.Sprintf("ValidatingWebhookConfiguration %s webhook %s", name, webhook.Name),
+					fmt.Errorf("webhook %s in %s has no CA bundle yet", webhook.Name, name)
+			}
+		}
+	}
+
+	for _, name := range r.MutatingWebhooks {
+		var config admissionregistrationv1.MutatingWebhookConfiguration
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: name}, &config); err != nil {
+			return fmt.Sprintf("MutatingWebhookConfiguration %s not found", name), err
+		}
+		for _, webhook := range config.Webhooks {
+			if len(webhook.ClientConfig.CABundle) == 0 {
+				return fmt.Sprintf("MutatingWebhookConfiguration %s webhook %s", name, webhook.Name),
+					fmt.Errorf("webhook %s in %s has no CA bundle yet", webhook.Name, name)
+			}
+		}
+	}
+	return "", nil
+}
+
+// checkGVKVersions delegates to gvkcheck, the same verifier the operator's
+// own startup path runs, to confirm every tracked GVK is served at its own
+// version and that its CRD's conversion webhook, if any, is reachable.
+// This is the "single-Kind mapping probe" this suite used to run ad hoc.
+func (r *InstallationReadiness) checkGVKVersions(ctx context.Context) (string, error) {
+	watched := make([]gvkcheck.WatchedGVK, 0, len(r.GVKs))
+	for _, gvk := range r.GVKs {
+		watched = append(watched, gvkcheck.WatchedGVK{GVK: gvk, PreferredVersion: gvk.Version})
+	}
+
+	verifier := gvkcheck.Verifier{Client: r.Client, Watched: watched}
+	mismatches, err := verifier.Verify(ctx)
+	if err != nil {
+		return "", err
+	}
+	fatal := 0
+	for _, m := range mismatches {
+		if m.Severity == gvkcheck.SeverityFatal {
+			fatal++
+		}
+	}
+	if fatal > 0 {
+		return fmt.Sprintf("%+v", mismatches), fmt.Errorf("%d GVK check(s) failed verification", fatal)
+	}
+	return "", nil
+}
@@ -0,0 +1,67 @@
+/*
+This file is part of Cloud Native PostgreSQL.
+
+Copyright (C) 2019-2021 EnterpriseDB Corporation.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var releaseManifestPattern = regexp.MustCompile(`^postgresql-operator-(\d+)\.(\d+)\.(\d+)\.yaml$`)
+
+// GetMostRecentReleaseTag scans releasesDir for postgresql-operator-X.Y.Z.yaml
+// manifests and returns the semver tag of the highest one found. It is how
+// the upgrade e2e suite decides which previously released operator to
+// install before applying the manifest built from the current checkout on
+// top of it.
+func GetMostRecentReleaseTag(releasesDir string) (string, error) {
+	entries, err := os.ReadDir(releasesDir)
+	if err != nil {
+		return "", fmt.Errorf("while reading release manifests from %s: %w", releasesDir, err)
+	}
+
+	var versions [][3]int
+	var tags []string
+	for _, entry := range entries {
+		matches := releaseManifestPattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+
+		major, _ := strconv.Atoi(matches[1])
+		minor, _ := strconv.Atoi(matches[2])
+		patch, _ := strconv.Atoi(matches[3])
+		versions = append(versions, [3]int{major, minor, patch})
+		tags = append(tags, strings.Join(matches[1:], "."))
+	}
+
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no postgresql-operator-X.Y.Z.yaml release manifest found in %s", releasesDir)
+	}
+
+	best := 0
+	for i := range versions {
+		if versionGreater(versions[i], versions[best]) {
+			best = i
+		}
+	}
+	return tags[best], nil
+}
+
+// versionGreater compares two [major, minor, patch] triples lexicographically,
+// since this repo has no semver library as a dependency.
+func versionGreater(a, b [3]int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}